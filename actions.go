@@ -0,0 +1,414 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// The actions in this file change the state of a talking list. They are shared by the
+// REST endpoints and the moderator channel, so they do not write any response.
+// listsMutex has to be held while calling them.
+
+// listActionError describes why an action on a talking list failed
+type listActionError struct {
+	// HTTP status code to respond with
+	Status int
+
+	// Machine readable kind of the error, see APIError
+	Code string
+
+	// Human readable description of the error
+	Message string
+}
+
+func (err *listActionError) Error() string {
+	return err.Message
+}
+
+var (
+	errListNotFound        = &listActionError{http.StatusNotFound, errorCodeNotFound, "The talking list does not exist"}
+	errGroupNotFound       = &listActionError{http.StatusNotFound, errorCodeNotFound, "The group does not exist"}
+	errApplicationNotFound = &listActionError{http.StatusNotFound, errorCodeNotFound, "The application does not exist"}
+
+	errNoContributionToReplyTo = &listActionError{http.StatusConflict, errorCodeConflict, "Direct replies are only possible while somebody is speaking"}
+	errApplicationLimitReached = &listActionError{http.StatusConflict, errorCodeConflict, "No more applications of this kind are allowed at the moment"}
+	errNoContribution          = &listActionError{http.StatusConflict, errorCodeConflict, "No contribution is in progress"}
+	errContributionPaused      = &listActionError{http.StatusConflict, errorCodeConflict, "The contribution is already paused"}
+	errContributionNotPaused   = &listActionError{http.StatusConflict, errorCodeConflict, "The contribution is not paused"}
+	errNothingToUndo           = &listActionError{http.StatusConflict, errorCodeConflict, "There is no start or stop of a contribution to undo"}
+	errUndoOutdated            = &listActionError{http.StatusConflict, errorCodeConflict, "The contributions changed since the last start or stop, so it can no longer be undone"}
+	errAgendaFinished          = &listActionError{http.StatusConflict, errorCodeConflict, "There is no further agenda item"}
+)
+
+// Actions on contributions that can be undone
+const (
+	undoActionStart = "start_contribution"
+	undoActionStop  = "stop_contribution"
+)
+
+// contributionUndo records what is needed to revert the last start or stop of a contribution
+type contributionUndo struct {
+	// The action to revert, one of the undoAction constants
+	action string
+
+	// The current contribution before the action.
+	// It shares its pauses with the contribution that was stopped, so they are copied before the pause is ended.
+	previous TalkingListContribution
+
+	// The contribution started by the action, when undoing a start
+	started uuid.UUID
+
+	// The application the started contribution consists of, with its group and position
+	groupUuid       uuid.UUID
+	applicationUuid uuid.UUID
+	application     TalkingListApplication
+}
+
+// Abort the request because an action failed
+func abortWithActionError(context *gin.Context, err error) {
+	var actionErr *listActionError
+	if errors.As(err, &actionErr) {
+		abortWithError(context, actionErr.Status, actionErr.Code, actionErr.Message)
+		return
+	}
+
+	abortWithError(context, http.StatusInternalServerError, errorCodeInternal, err.Error())
+}
+
+// Look up a list and one of its groups
+func findGroup(listUuid uuid.UUID, groupUuid uuid.UUID) (TalkingList, TalkingListGroup, error) {
+	listEntry, entryPresent := lists[listUuid]
+	if !entryPresent {
+		return TalkingList{}, TalkingListGroup{}, errListNotFound
+	}
+
+	groupEntry, entryPresent := listEntry.Groups[groupUuid]
+	if !entryPresent {
+		return TalkingList{}, TalkingListGroup{}, errGroupNotFound
+	}
+
+	return listEntry, groupEntry, nil
+}
+
+// Start the contribution of an application, a running contribution is stopped before.
+// The application is removed from its group.
+func startApplicationContribution(context *gin.Context, listUuid uuid.UUID, groupUuid uuid.UUID, applicationUuid uuid.UUID) (TalkingListContribution, error) {
+	listEntry, groupEntry, err := findGroup(listUuid, groupUuid)
+	if err != nil {
+		return TalkingListContribution{}, err
+	}
+
+	applicationEntry, entryPresent := groupEntry.Applications[applicationUuid]
+	if !entryPresent {
+		return TalkingListContribution{}, errApplicationNotFound
+	}
+
+	now := time.Now()
+	stopped := listEntry.CurrentContribution.InProgress
+	previous := listEntry.CurrentContribution
+	listEntry.finishCurrentContribution(now)
+
+	listEntry.CurrentContribution = TalkingListContribution{
+		Uuid:           uuid.New(),
+		InProgress:     true,
+		Application:    applicationEntry,
+		GroupUuid:      groupUuid,
+		AgendaItemUuid: listEntry.currentAgendaItem(),
+		StartTime:      now,
+		TimeLimit:      listEntry.speakingTimeLimit(groupUuid),
+	}
+	listEntry.CurrentContribution.Application.Position = 0
+	listEntry.undo = &contributionUndo{
+		action:          undoActionStart,
+		previous:        previous,
+		started:         listEntry.CurrentContribution.Uuid,
+		groupUuid:       groupUuid,
+		applicationUuid: applicationUuid,
+		application:     applicationEntry,
+	}
+
+	delete(groupEntry.Applications, applicationUuid)
+	groupEntry.setQueue(groupEntry.queue())
+	listEntry.Groups[groupUuid] = groupEntry
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "start_contribution", "group", groupUuid, "application", applicationUuid)
+	if stopped {
+		publishListEvent(listUuid, eventContributionStopped, listEntry.PastContributions[len(listEntry.PastContributions)-1])
+	}
+	publishListEvent(listUuid, eventApplicationDeleted, ApplicationEvent{GroupUuid: groupUuid, ApplicationUuid: applicationUuid})
+	publishListEvent(listUuid, eventContributionStarted, listEntry.CurrentContribution)
+
+	return listEntry.CurrentContribution, nil
+}
+
+// Stop the running contribution, if there is one
+func stopCurrentContribution(context *gin.Context, listUuid uuid.UUID) error {
+	listEntry, entryPresent := lists[listUuid]
+	if !entryPresent {
+		return errListNotFound
+	}
+
+	// Nothing changes, so the revision stays the same and long-polling clients are not woken up
+	if !listEntry.CurrentContribution.InProgress {
+		return nil
+	}
+
+	listEntry.undo = &contributionUndo{action: undoActionStop, previous: listEntry.CurrentContribution}
+	listEntry.finishCurrentContribution(time.Now())
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "stop_contribution")
+	publishListEvent(listUuid, eventContributionStopped, listEntry.PastContributions[len(listEntry.PastContributions)-1])
+
+	return nil
+}
+
+// Remove an application from its group
+func withdrawApplication(context *gin.Context, listUuid uuid.UUID, groupUuid uuid.UUID, applicationUuid uuid.UUID) error {
+	listEntry, groupEntry, err := findGroup(listUuid, groupUuid)
+	if err != nil {
+		return err
+	}
+
+	if _, entryPresent := groupEntry.Applications[applicationUuid]; !entryPresent {
+		return errApplicationNotFound
+	}
+
+	delete(groupEntry.Applications, applicationUuid)
+	groupEntry.setQueue(groupEntry.queue())
+	listEntry.Groups[groupUuid] = groupEntry
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "delete_application", "group", groupUuid, "application", applicationUuid)
+	publishListEvent(listUuid, eventApplicationDeleted, ApplicationEvent{GroupUuid: groupUuid, ApplicationUuid: applicationUuid})
+
+	return nil
+}
+
+// Move an application to another position in the queue of its group, starting at 1.
+// Positions after the end of the queue move the application to the end.
+func moveApplication(context *gin.Context, listUuid uuid.UUID, groupUuid uuid.UUID, applicationUuid uuid.UUID, position int) ([]TalkingListQueuedApplication, error) {
+	listEntry, groupEntry, err := findGroup(listUuid, groupUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, entryPresent := groupEntry.Applications[applicationUuid]; !entryPresent {
+		return nil, errApplicationNotFound
+	}
+
+	order := groupEntry.queue()
+	position = max(1, min(position, len(order)))
+	for index, queuedUuid := range order {
+		if queuedUuid == applicationUuid {
+			order = append(order[:index], order[index+1:]...)
+			break
+		}
+	}
+	order = append(order[:position-1], append([]uuid.UUID{applicationUuid}, order[position-1:]...)...)
+
+	groupEntry.setQueue(order)
+	listEntry.Groups[groupUuid] = groupEntry
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "move_application", "group", groupUuid, "application", applicationUuid, "position", position)
+	publishListEvent(listUuid, eventApplicationsReordered, QueueEvent{GroupUuid: groupUuid, Order: order})
+
+	return listEntry.queuedApplications(groupUuid), nil
+}
+
+// Stop the running contribution and start the one of the application to be called next
+// according to the queue policy of the list
+func startNextContribution(context *gin.Context, listUuid uuid.UUID) (TalkingListNext, error) {
+	listEntry, entryPresent := lists[listUuid]
+	if !entryPresent {
+		return TalkingListNext{}, errListNotFound
+	}
+
+	// Nobody applied, so only the running contribution ends
+	speaker, ok := listEntry.nextSpeaker()
+	if !ok {
+		return TalkingListNext{}, stopCurrentContribution(context, listUuid)
+	}
+
+	contribution, err := startApplicationContribution(context, listUuid, speaker.GroupUuid, speaker.ApplicationUuid)
+	if err != nil {
+		return TalkingListNext{}, err
+	}
+
+	next := TalkingListNext{Current: &contribution}
+	listEntry = lists[listUuid]
+	if following, ok := listEntry.nextSpeaker(); ok {
+		next.Following = &following
+	}
+	return next, nil
+}
+
+// Pause the running contribution, the pause does not count as speaking time
+func pauseCurrentContribution(context *gin.Context, listUuid uuid.UUID) (TalkingListContribution, error) {
+	listEntry, entryPresent := lists[listUuid]
+	if !entryPresent {
+		return TalkingListContribution{}, errListNotFound
+	}
+
+	contribution := &listEntry.CurrentContribution
+	if !contribution.InProgress {
+		return TalkingListContribution{}, errNoContribution
+	}
+	if contribution.Paused {
+		return TalkingListContribution{}, errContributionPaused
+	}
+
+	contribution.Pauses = append(contribution.Pauses, TalkingListPause{StartTime: time.Now()})
+	contribution.Paused = true
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "pause_contribution")
+	publishListEvent(listUuid, eventContributionPaused, listEntry.CurrentContribution)
+
+	return listEntry.CurrentContribution, nil
+}
+
+// Resume the paused contribution
+func resumeCurrentContribution(context *gin.Context, listUuid uuid.UUID) (TalkingListContribution, error) {
+	listEntry, entryPresent := lists[listUuid]
+	if !entryPresent {
+		return TalkingListContribution{}, errListNotFound
+	}
+
+	contribution := &listEntry.CurrentContribution
+	if !contribution.InProgress {
+		return TalkingListContribution{}, errNoContribution
+	}
+	if !contribution.Paused {
+		return TalkingListContribution{}, errContributionNotPaused
+	}
+
+	contribution.Pauses[len(contribution.Pauses)-1].EndTime = time.Now()
+	contribution.Paused = false
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "resume_contribution")
+	publishListEvent(listUuid, eventContributionResumed, listEntry.CurrentContribution)
+
+	return listEntry.CurrentContribution, nil
+}
+
+// Revert the last start or stop of a contribution, as long as the contributions did not change since.
+// Undoing a start returns the application to its position in the queue and continues the contribution
+// that was stopped by it. A stopped contribution continues as if it had never been stopped.
+func undoContributionAction(context *gin.Context, listUuid uuid.UUID) (TalkingListContribution, error) {
+	listEntry, entryPresent := lists[listUuid]
+	if !entryPresent {
+		return TalkingListContribution{}, errListNotFound
+	}
+
+	undo := listEntry.undo
+	if undo == nil {
+		return TalkingListContribution{}, errNothingToUndo
+	}
+
+	// The contribution that was running before the action has to be the latest past one
+	current := listEntry.CurrentContribution
+	past := listEntry.PastContributions
+	reopened := undo.previous.InProgress
+	if reopened && (len(past) == 0 || past[len(past)-1].Uuid != undo.previous.Uuid) {
+		return TalkingListContribution{}, errUndoOutdated
+	}
+	switch undo.action {
+	case undoActionStart:
+		if !current.InProgress || current.Uuid != undo.started {
+			return TalkingListContribution{}, errUndoOutdated
+		}
+	case undoActionStop:
+		if current.InProgress {
+			return TalkingListContribution{}, errUndoOutdated
+		}
+	}
+
+	var groupEntry TalkingListGroup
+	var order []uuid.UUID
+	if undo.action == undoActionStart {
+		if groupEntry, entryPresent = listEntry.Groups[undo.groupUuid]; !entryPresent {
+			return TalkingListContribution{}, errGroupNotFound
+		}
+
+		order = groupEntry.queue()
+		position := max(1, min(undo.application.Position, len(order)+1))
+		order = append(order[:position-1], append([]uuid.UUID{undo.applicationUuid}, order[position-1:]...)...)
+		groupEntry.Applications[undo.applicationUuid] = undo.application
+		groupEntry.setQueue(order)
+		listEntry.Groups[undo.groupUuid] = groupEntry
+	}
+
+	if reopened {
+		listEntry.PastContributions = past[:len(past)-1]
+	}
+	contributionUuid := undo.previous.Uuid
+	if undo.action == undoActionStart {
+		contributionUuid = undo.started
+	}
+	listEntry.CurrentContribution = undo.previous
+	listEntry.undo = nil
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "undo_contribution", "undone", undo.action)
+	auditListAction(context, listUuid, auditActionUndoContribution, "undone", undo.action, "contribution", contributionUuid)
+
+	if undo.action == undoActionStart {
+		application := groupEntry.Applications[undo.applicationUuid]
+		publishListEvent(listUuid, eventApplicationCreated, ApplicationEvent{GroupUuid: undo.groupUuid, ApplicationUuid: undo.applicationUuid, Application: &application})
+		publishListEvent(listUuid, eventApplicationsReordered, QueueEvent{GroupUuid: undo.groupUuid, Order: order})
+	}
+	undoEvent := UndoEvent{Action: undo.action, CurrentContribution: listEntry.CurrentContribution}
+	if reopened {
+		undoEvent.ReopenedContributionUuid = &undo.previous.Uuid
+	}
+	publishListEvent(listUuid, eventContributionUndone, undoEvent)
+
+	return listEntry.CurrentContribution, nil
+}
+
+// Move on to the next agenda item, or to the first one if none is current yet.
+// A running contribution stays with the agenda item it started in.
+func advanceAgenda(context *gin.Context, listUuid uuid.UUID) (TalkingListAgenda, error) {
+	listEntry, entryPresent := lists[listUuid]
+	if !entryPresent {
+		return TalkingListAgenda{}, errListNotFound
+	}
+
+	next := 0
+	if listEntry.Agenda.Current != nil {
+		next = listEntry.agendaIndex(listEntry.Agenda.Current) + 1
+	}
+	if next >= len(listEntry.Agenda.Items) {
+		return TalkingListAgenda{}, errAgendaFinished
+	}
+
+	current := listEntry.Agenda.Items[next].Uuid
+	listEntry.Agenda.Current = &current
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "next_agenda_item", "agenda_item", current)
+	publishListEvent(listUuid, eventAgendaChanged, listEntry.Agenda)
+
+	return listEntry.Agenda, nil
+}
@@ -0,0 +1,75 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"github.com/google/uuid"
+)
+
+// Find an agenda item by its UUID, returns -1 if there is none
+func (list *TalkingList) agendaIndex(itemUuid *uuid.UUID) int {
+	if itemUuid == nil {
+		return -1
+	}
+	for index, item := range list.Agenda.Items {
+		if item.Uuid == *itemUuid {
+			return index
+		}
+	}
+	return -1
+}
+
+// Get the UUID of the agenda item that is currently discussed, nil if there is none.
+// The UUID is copied, so it can be stored with a contribution.
+func (list *TalkingList) currentAgendaItem() *uuid.UUID {
+	if list.Agenda.Current == nil {
+		return nil
+	}
+	current := *list.Agenda.Current
+	return &current
+}
+
+// Check whether two references to agenda items refer to the same one, or both to none
+func sameAgendaItem(a *uuid.UUID, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// Check whether any contribution, including the running one, or any motion belongs to an agenda item
+func (list *TalkingList) agendaItemInUse(itemUuid uuid.UUID) bool {
+	if list.CurrentContribution.InProgress && sameAgendaItem(list.CurrentContribution.AgendaItemUuid, &itemUuid) {
+		return true
+	}
+	for _, contribution := range list.PastContributions {
+		if sameAgendaItem(contribution.AgendaItemUuid, &itemUuid) {
+			return true
+		}
+	}
+	for _, motion := range list.Motions {
+		if sameAgendaItem(motion.AgendaItemUuid, &itemUuid) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Add items to the agenda of a list through the API and return their UUIDs
+func addTestAgenda(t *testing.T, router *gin.Engine, token string, listUuid uuid.UUID, titles ...string) []uuid.UUID {
+	t.Helper()
+
+	var items []uuid.UUID
+	for _, title := range titles {
+		response := doRequest(router, http.MethodPost, "/v1/protected/list/"+listUuid.String()+"/agenda", `{"title": "`+title+`"}`, token)
+		var item TalkingListAgendaItem
+		if err := json.Unmarshal(response.Body.Bytes(), &item); err != nil || response.Code != http.StatusCreated {
+			t.Fatalf("Adding the agenda item %s returned status %d: %s", title, response.Code, response.Body.String())
+		}
+		items = append(items, item.Uuid)
+	}
+	return items
+}
+
+// Get the titles of the agenda items of a list, the current one marked with a star
+func agendaTitles(listUuid uuid.UUID) string {
+	listEntry := lists[listUuid]
+	var titles []string
+	for _, item := range listEntry.Agenda.Items {
+		if sameAgendaItem(listEntry.Agenda.Current, &item.Uuid) {
+			item.Title += "*"
+		}
+		titles = append(titles, item.Title)
+	}
+	return strings.Join(titles, " ")
+}
+
+func TestManageAgenda(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+	listUuid, _, _ := addTestList(VisibilityPublic)
+	listPath := "/v1/protected/list/" + listUuid.String()
+	items := addTestAgenda(t, router, token, listUuid, "Begrüßung", "Haushalt", "Sonstiges")
+
+	doRequest(router, http.MethodPost, listPath+"/agenda/"+items[2].String()+"/position", `{"position": 1}`, token)
+	doRequest(router, http.MethodPost, listPath+"/agenda/"+items[1].String(), `{"title": "Finanzen"}`, token)
+	if titles := agendaTitles(listUuid); titles != "Sonstiges Begrüßung Finanzen" {
+		t.Errorf("The agenda is %q after moving and renaming", titles)
+	}
+
+	for _, expected := range []string{"Sonstiges* Begrüßung Finanzen", "Sonstiges Begrüßung* Finanzen"} {
+		if response := doRequest(router, http.MethodPost, listPath+"/next_agenda_item", "", token); response.Code != http.StatusOK {
+			t.Fatalf("Advancing the agenda returned status %d", response.Code)
+		}
+		if titles := agendaTitles(listUuid); titles != expected {
+			t.Errorf("The agenda is %q, expected %q", titles, expected)
+		}
+	}
+
+	doRequest(router, http.MethodPost, listPath+"/agenda/"+items[1].String()+"/current", "", token)
+	if response := doRequest(router, http.MethodPost, listPath+"/next_agenda_item", "", token); response.Code != http.StatusConflict {
+		t.Errorf("Advancing past the last agenda item returned status %d, expected %d", response.Code, http.StatusConflict)
+	}
+
+	doRequest(router, http.MethodDelete, listPath+"/agenda/"+items[1].String(), "", token)
+	if titles := agendaTitles(listUuid); titles != "Sonstiges Begrüßung" || lists[listUuid].Agenda.Current != nil {
+		t.Errorf("The agenda is %q after deleting the current item", titles)
+	}
+
+	response := doRequest(router, http.MethodGet, "/v1/public/list/"+listUuid.String()+"/agenda", "", "")
+	var agenda TalkingListAgenda
+	if err := json.Unmarshal(response.Body.Bytes(), &agenda); err != nil || len(agenda.Items) != 2 {
+		t.Errorf("The public agenda is %s", response.Body.String())
+	}
+}
+
+func TestCreatedAgendaItemCanBeRetrieved(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+	listUuid, _, _ := addTestList(VisibilityPublic)
+
+	response := doRequest(router, http.MethodPost, "/v1/protected/list/"+listUuid.String()+"/agenda", `{"title": "Haushalt"}`, token)
+	location := response.Header().Get("Location")
+	if response.Code != http.StatusCreated || location == "" {
+		t.Fatalf("Adding an agenda item returned status %d and Location %q", response.Code, location)
+	}
+
+	for _, path := range []string{location, strings.Replace(location, "/protected/", "/public/", 1)} {
+		response := doRequest(router, http.MethodGet, path, "", token)
+		var item TalkingListAgendaItem
+		if err := json.Unmarshal(response.Body.Bytes(), &item); err != nil || response.Code != http.StatusOK || item.Title != "Haushalt" {
+			t.Errorf("GET %s returned status %d: %s", path, response.Code, response.Body.String())
+		}
+	}
+
+	response = doRequest(router, http.MethodGet, "/v1/public/list/"+listUuid.String()+"/agenda/"+uuid.New().String(), "", "")
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Retrieving an unknown agenda item returned status %d, expected %d", response.Code, http.StatusNotFound)
+	}
+}
+
+func TestContributionsBelongToAgendaItems(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+	listUuid, groupUuid, aliceUuid := addTestList(VisibilityPublic)
+	bobUuid := addTestApplication(listUuid, groupUuid, "Bob")
+	listPath := "/v1/protected/list/" + listUuid.String()
+	items := addTestAgenda(t, router, token, listUuid, "Begrüßung", "Haushalt")
+
+	// Without a current agenda item, contributions do not belong to any
+	doRequest(router, http.MethodPost, listPath+"/start_contribution", `{"group_uuid": "`+groupUuid.String()+`", "application_uuid": "`+aliceUuid.String()+`"}`, token)
+	doRequest(router, http.MethodPost, listPath+"/next_agenda_item", "", token)
+	doRequest(router, http.MethodPost, listPath+"/next_agenda_item", "", token)
+	doRequest(router, http.MethodPost, listPath+"/start_contribution", `{"group_uuid": "`+groupUuid.String()+`", "application_uuid": "`+bobUuid.String()+`"}`, token)
+	doRequest(router, http.MethodPost, listPath+"/stop_contribution", "", token)
+
+	past := lists[listUuid].PastContributions
+	if len(past) != 2 || past[0].AgendaItemUuid != nil || !sameAgendaItem(past[1].AgendaItemUuid, &items[1]) {
+		t.Fatalf("The contributions belong to the wrong agenda items: %+v", past)
+	}
+
+	if response := doRequest(router, http.MethodDelete, listPath+"/agenda/"+items[1].String(), "", token); response.Code != http.StatusConflict {
+		t.Errorf("Deleting an agenda item with contributions returned status %d, expected %d", response.Code, http.StatusConflict)
+	}
+
+	var distribution TalkingListTimeDistribution
+	response := doRequest(router, http.MethodGet, "/v1/public/list/"+listUuid.String()+"/time_distribution", "", "")
+	if err := json.Unmarshal(response.Body.Bytes(), &distribution); err != nil {
+		t.Fatalf("Decoding the time distribution failed: %v", err)
+	}
+	if len(distribution.Agenda) != 2 || distribution.Agenda[0].NumberContributions[groupUuid] != 0 ||
+		distribution.Agenda[1].NumberContributions[groupUuid] != 1 || distribution.Agenda[1].TotalTime != past[1].Duration {
+		t.Errorf("The time distribution by agenda item is %+v", distribution.Agenda)
+	}
+
+	report := doRequest(router, http.MethodGet, listPath+"/mdreport", "", token).Body.String()
+	if !strings.Contains(report, "## Tagesordnung") || !strings.Contains(report, "### TOP 2: Haushalt") {
+		t.Errorf("The report does not break down by agenda item:\n%s", report)
+	}
+}
+
+func TestFirstTimeSpeakersPerAgendaItem(t *testing.T) {
+	setupTestRouter(t)
+	listUuid, groupUuid, aliceUuid := addTestList(VisibilityPublic)
+	bobUuid := addTestApplication(listUuid, groupUuid, "Bob")
+
+	first, second := uuid.New(), uuid.New()
+	listEntry := lists[listUuid]
+	listEntry.QueuePolicy.FirstTimeSpeakersFirst = true
+	listEntry.Agenda = TalkingListAgenda{Items: []TalkingListAgendaItem{{Uuid: first, Title: "A"}, {Uuid: second, Title: "B"}}, Current: &first}
+	listEntry.PastContributions = []TalkingListContribution{
+		{Application: TalkingListApplication{Name: "Alice"}, GroupUuid: groupUuid, AgendaItemUuid: &first},
+	}
+
+	if speaker, _ := listEntry.nextSpeaker(); speaker.ApplicationUuid != bobUuid {
+		t.Errorf("Alice spoke on this agenda item, but %s is called first", speaker.Application.Name)
+	}
+
+	listEntry.Agenda.Current = &second
+	if speaker, _ := listEntry.nextSpeaker(); speaker.ApplicationUuid != aliceUuid {
+		t.Errorf("Nobody spoke on this agenda item yet, but %s is called first", speaker.Application.Name)
+	}
+}
+
+func TestSpeakingOrderMatchesNextOnAgendaItem(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+	listUuid, groupUuid, aliceUuid := addTestList(VisibilityPublic)
+	listPath := "/v1/protected/list/" + listUuid.String()
+	applicationsPath := "/v1/public/list/" + listUuid.String() + "/group/" + groupUuid.String() + "/application"
+	doRequest(router, http.MethodPost, listPath+"/queue_policy", `{"policy": "fifo", "first_time_speakers_first": true}`, token)
+	addTestAgenda(t, router, token, listUuid, "Begrüßung", "Finanzen")
+	doRequest(router, http.MethodPost, listPath+"/next_agenda_item", "", token)
+
+	// Alice already spoke on the current agenda item and applies again before Bob
+	doRequest(router, http.MethodPost, listPath+"/start_contribution", `{"group_uuid": "`+groupUuid.String()+`", "application_uuid": "`+aliceUuid.String()+`"}`, token)
+	doRequest(router, http.MethodPost, listPath+"/stop_contribution", "", token)
+	doRequest(router, http.MethodPost, applicationsPath, `{"name": "Alice"}`, "")
+	doRequest(router, http.MethodPost, applicationsPath, `{"name": "Bob"}`, "")
+
+	var order []TalkingListSpeaker
+	response := doRequest(router, http.MethodGet, listPath+"/speaking_order", "", token)
+	if err := json.Unmarshal(response.Body.Bytes(), &order); err != nil || len(order) != 2 {
+		t.Fatalf("The speaking order is %s", response.Body.String())
+	}
+
+	var next TalkingListNext
+	response = doRequest(router, http.MethodPost, listPath+"/next", "", token)
+	if err := json.Unmarshal(response.Body.Bytes(), &next); err != nil || next.Current == nil {
+		t.Fatalf("Calling the next speaker returned status %d: %s", response.Code, response.Body.String())
+	}
+
+	if order[0].Application.Name != "Bob" || next.Current.Application.Name != order[0].Application.Name {
+		t.Errorf("The speaking order starts with %s, but %s was called next", order[0].Application.Name, next.Current.Application.Name)
+	}
+}
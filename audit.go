@@ -0,0 +1,310 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Actions recorded in the audit log
+const (
+	auditActionLogin                  = "login"
+	auditActionLoginFailed            = "login_failed"
+	auditActionDeleteList             = "delete_list"
+	auditActionUpdateVisibility       = "update_visibility"
+	auditActionDeleteGroup            = "delete_group"
+	auditActionDeleteAttendee         = "delete_attendee"
+	auditActionResetPastContributions = "reset_past_contributions"
+	auditActionUndoContribution       = "undo_contribution"
+	auditActionEditContribution       = "edit_contribution"
+	auditActionDeleteContribution     = "delete_contribution"
+	auditActionCloseMotion            = "close_motion"
+	auditActionDeleteMotion           = "delete_motion"
+)
+
+// Limits of the number of entries returned by a single audit log query
+const (
+	auditDefaultLimit = 50
+	auditMaxLimit     = 1000
+)
+
+// AuditEntry records who did what, when and from where
+type AuditEntry struct {
+	// The time of the action
+	Time time.Time `json:"time"`
+
+	// The name of the user who did it, for failed logins the given username
+	User string `json:"user"`
+
+	// The IP address the request came from
+	ClientIP string `json:"client_ip"`
+
+	// The ID of the request, see requestIdMiddleware
+	RequestId string `json:"request_id,omitempty"`
+
+	// What was done, one of the auditAction constants
+	Action string `json:"action"`
+
+	// The talking list that was affected, if any
+	List *uuid.UUID `json:"list,omitempty"`
+
+	// Further information depending on the action
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// AuditQueryResult is the response to a query of the audit log
+type AuditQueryResult struct {
+	// The matching entries, newest first
+	Entries []AuditEntry `json:"entries"`
+
+	// The number of matching entries, regardless of pagination
+	Total int `json:"total"`
+
+	// The number of matching entries that were skipped
+	Offset int `json:"offset"`
+
+	// The maximum number of entries returned
+	Limit int `json:"limit"`
+}
+
+// The audit log file, it is only ever appended to
+var auditLog struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// Open the audit log for appending, creating it if necessary
+func auditSetup() error {
+	auditLog.mutex.Lock()
+	defer auditLog.mutex.Unlock()
+
+	if auditLog.file != nil {
+		auditLog.file.Close()
+	}
+
+	file, err := os.OpenFile(cfg.Database.AuditLogPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	auditLog.file = file
+
+	return nil
+}
+
+// Close the audit log, all entries are already on disk
+func auditClose() error {
+	auditLog.mutex.Lock()
+	defer auditLog.mutex.Unlock()
+
+	if auditLog.file == nil {
+		return nil
+	}
+	err := auditLog.file.Close()
+	auditLog.file = nil
+
+	return err
+}
+
+// Create an audit entry for an action done in a request
+func newAuditEntry(context *gin.Context, action string) AuditEntry {
+	return AuditEntry{
+		Time:      time.Now(),
+		User:      contextUsername(context),
+		ClientIP:  context.ClientIP(),
+		RequestId: context.GetString(requestIdContextKey),
+		Action:    action,
+	}
+}
+
+// Append an entry to the audit log.
+// Failures are logged, the action itself has already happened at this point.
+func writeAuditEntry(entry AuditEntry) {
+	line, err := json.Marshal(entry)
+	if err == nil {
+		auditLog.mutex.Lock()
+		if auditLog.file == nil {
+			err = os.ErrClosed
+		} else {
+			_, err = auditLog.file.Write(append(line, '\n'))
+		}
+		auditLog.mutex.Unlock()
+	}
+
+	if err != nil {
+		slog.Error("Failed to write audit log", "error", err, "action", entry.Action, "user", entry.User)
+	}
+}
+
+// Record a sensitive action on a talking list in the audit log.
+// The details are given as alternating keys and values, like for logListAction.
+func auditListAction(context *gin.Context, listUuid uuid.UUID, action string, details ...any) {
+	entry := newAuditEntry(context, action)
+	entry.List = &listUuid
+
+	if len(details) > 0 {
+		entry.Details = make(map[string]any)
+		for i := 0; i+1 < len(details); i += 2 {
+			entry.Details[fmt.Sprint(details[i])] = details[i+1]
+		}
+	}
+
+	writeAuditEntry(entry)
+}
+
+// Record an attempt to log in in the audit log
+func auditLogin(context *gin.Context, username string, success bool, reason string) {
+	action := auditActionLogin
+	if !success {
+		action = auditActionLoginFailed
+	}
+
+	entry := newAuditEntry(context, action)
+	entry.User = username
+	if reason != "" {
+		entry.Details = map[string]any{"reason": reason}
+	}
+
+	writeAuditEntry(entry)
+}
+
+// Middleware that only lets administrators pass.
+// It has to be used after the authentication middleware.
+func requireAdmin() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		identity, _ := context.Get(authMiddleware.IdentityKey)
+		if user, ok := identity.(*User); !ok || user == nil || !user.IsAdmin {
+			abortWithError(context, http.StatusForbidden, errorCodeForbidden, "Only administrators may access this endpoint")
+			return
+		}
+
+		context.Next()
+	}
+}
+
+// Register the endpoints to query the audit log
+func setupAuditRoutes(admin *gin.RouterGroup) {
+	admin.GET("/audit", getAuditEntries)
+}
+
+// Query the audit log.
+// Entries can be filtered by user, action, list and time range and are returned newest first.
+func getAuditEntries(context *gin.Context) {
+	var fields []APIFieldError
+	var since, until time.Time
+	var listUuid uuid.UUID
+	limit, offset := auditDefaultLimit, 0
+
+	if value := context.Query("list"); value != "" {
+		var err error
+		if listUuid, err = uuid.Parse(value); err != nil {
+			fields = append(fields, APIFieldError{Field: "list", Message: "is not a valid UUID"})
+		}
+	}
+	for name, target := range map[string]*time.Time{"since": &since, "until": &until} {
+		if value := context.Query(name); value != "" {
+			var err error
+			if *target, err = time.Parse(time.RFC3339, value); err != nil {
+				fields = append(fields, APIFieldError{Field: name, Message: "is not an RFC 3339 timestamp"})
+			}
+		}
+	}
+	if value := context.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > auditMaxLimit {
+			fields = append(fields, APIFieldError{Field: "limit", Message: fmt.Sprintf("has to be between 1 and %d", auditMaxLimit)})
+		}
+	}
+	if value := context.Query("offset"); value != "" {
+		var err error
+		if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+			fields = append(fields, APIFieldError{Field: "offset", Message: "has to be a non-negative number"})
+		}
+	}
+	if len(fields) > 0 {
+		abortWithError(context, http.StatusBadRequest, errorCodeInvalidParameter, "A parameter is invalid", fields...)
+		return
+	}
+
+	user, action := context.Query("user"), context.Query("action")
+	matches := func(entry AuditEntry) bool {
+		return (user == "" || entry.User == user) &&
+			(action == "" || entry.Action == action) &&
+			(listUuid == uuid.Nil || (entry.List != nil && *entry.List == listUuid)) &&
+			(since.IsZero() || !entry.Time.Before(since)) &&
+			(until.IsZero() || entry.Time.Before(until))
+	}
+
+	entries, err := readAuditEntries(matches)
+	if err != nil {
+		requestLogger(context).Error("Failed to read audit log", "error", err)
+		abortWithError(context, http.StatusInternalServerError, errorCodeInternal, "The audit log could not be read")
+		return
+	}
+
+	// The file is in chronological order, but the newest entries are usually the interesting ones
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	result := AuditQueryResult{Entries: []AuditEntry{}, Total: len(entries), Offset: offset, Limit: limit}
+	if offset < len(entries) {
+		result.Entries = entries[offset:min(offset+limit, len(entries))]
+	}
+
+	context.JSON(http.StatusOK, result)
+}
+
+// Read all entries of the audit log that match a filter, in chronological order
+func readAuditEntries(matches func(AuditEntry) bool) ([]AuditEntry, error) {
+	file, err := os.Open(cfg.Database.AuditLogPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A line may be incomplete if the server crashed while writing it
+			continue
+		}
+		if matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, scanner.Err()
+}
@@ -0,0 +1,139 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Query the audit log and decode the result
+func queryAudit(t *testing.T, router *gin.Engine, token string, query string) AuditQueryResult {
+	t.Helper()
+
+	response := doRequest(router, http.MethodGet, "/v1/protected/audit"+query, "", token)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Querying the audit log with %q returned status %d: %s", query, response.Code, response.Body.String())
+	}
+
+	var result AuditQueryResult
+	if err := json.Unmarshal(response.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Decoding audit log failed: %v", err)
+	}
+	return result
+}
+
+func TestAuditRecordsSensitiveActions(t *testing.T) {
+	router := setupTestRouter(t)
+	doRequest(router, http.MethodPost, "/v1/login", `{"username": "admin", "password": "wrong"}`, "")
+	token := login(t, router)
+	listUuid, groupUuid, _ := addTestList(VisibilityPublic)
+	listPath := "/v1/protected/list/" + listUuid.String()
+
+	doRequest(router, http.MethodPost, listPath+"/visibility", `{"new_visibility": 1}`, token)
+	doRequest(router, http.MethodPost, listPath+"/reset_past_contributions", "", token)
+	doRequest(router, http.MethodDelete, listPath+"/group/"+groupUuid.String(), "", token)
+	doRequest(router, http.MethodDelete, listPath, "", token)
+
+	result := queryAudit(t, router, token, "")
+	expected := []string{
+		auditActionDeleteList, auditActionDeleteGroup, auditActionResetPastContributions,
+		auditActionUpdateVisibility, auditActionLogin, auditActionLoginFailed,
+	}
+	if result.Total != len(expected) {
+		t.Fatalf("Expected %d entries, got %d: %+v", len(expected), result.Total, result.Entries)
+	}
+	for i, action := range expected {
+		entry := result.Entries[i]
+		if entry.Action != action || entry.User != "admin" || entry.ClientIP == "" {
+			t.Errorf("Entry %d is %+v, expected action %s by admin with an IP address", i, entry, action)
+		}
+	}
+
+	visibilityEntry := result.Entries[3]
+	if visibilityEntry.List == nil || *visibilityEntry.List != listUuid ||
+		visibilityEntry.Details["from"] != "public" || visibilityEntry.Details["to"] != "unlisted" {
+		t.Errorf("The visibility change was recorded as %+v", visibilityEntry)
+	}
+}
+
+func TestAuditQueryFiltersAndPaginates(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+	for i := 0; i < 4; i++ {
+		login(t, router)
+	}
+	listUuid, _, _ := addTestList(VisibilityPublic)
+	doRequest(router, http.MethodDelete, "/v1/protected/list/"+listUuid.String(), "", token)
+
+	if result := queryAudit(t, router, token, "?action=login"); result.Total != 5 {
+		t.Errorf("Filtering by action returned %d entries, expected 5", result.Total)
+	}
+	if result := queryAudit(t, router, token, "?list="+listUuid.String()); result.Total != 1 || result.Entries[0].Action != auditActionDeleteList {
+		t.Errorf("Filtering by list returned %+v", result.Entries)
+	}
+	if result := queryAudit(t, router, token, "?user=nobody"); result.Total != 0 || len(result.Entries) != 0 {
+		t.Errorf("Filtering by an unknown user returned %+v", result.Entries)
+	}
+	if result := queryAudit(t, router, token, "?until=2000-01-01T00:00:00Z"); result.Total != 0 {
+		t.Errorf("Filtering by time returned %+v", result.Entries)
+	}
+
+	result := queryAudit(t, router, token, "?limit=2&offset=1")
+	if result.Total != 6 || len(result.Entries) != 2 || result.Entries[0].Action != auditActionLogin {
+		t.Errorf("Pagination returned %d of %d entries: %+v", len(result.Entries), result.Total, result.Entries)
+	}
+
+	for _, query := range []string{"?limit=0", "?limit=abc", "?offset=-1", "?list=abc", "?since=yesterday"} {
+		response := doRequest(router, http.MethodGet, "/v1/protected/audit"+query, "", token)
+		if response.Code != http.StatusBadRequest {
+			t.Errorf("Querying with %q returned status %d, expected %d", query, response.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestAuditQueryRequiresAdmin(t *testing.T) {
+	router := setupTestRouter(t)
+	hash := sha256.Sum256([]byte("password"))
+	users = append(users, User{Username: "moderator", PasswordHash: hex.EncodeToString(hash[:])})
+
+	response := doRequest(router, http.MethodPost, "/v1/login", `{"username": "moderator", "password": "password"}`, "")
+	var body struct {
+		Token string `json:"token"`
+	}
+	json.Unmarshal(response.Body.Bytes(), &body)
+
+	response = doRequest(router, http.MethodGet, "/v1/protected/audit", "", body.Token)
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Querying the audit log as a regular user returned status %d, expected %d", response.Code, http.StatusForbidden)
+	}
+
+	response = doRequest(router, http.MethodGet, "/v1/protected/audit", "", "")
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Querying the audit log without a token returned status %d, expected %d", response.Code, http.StatusUnauthorized)
+	}
+}
@@ -71,6 +71,11 @@ func authSetup() error {
 		Timeout:     time.Duration(cfg.Authentication.TimeoutSeconds) * time.Second,
 		IdentityKey: "id",
 
+		// Errors are reported in the same format as by all other endpoints
+		Unauthorized: func(c *gin.Context, code int, message string) {
+			abortWithError(c, code, errorCodeUnauthorized, message)
+		},
+
 		// The PayloadFunc dumps the claims into the JSON Web Token
 		PayloadFunc: func(data interface{}) jwt.MapClaims {
 			if v, ok := data.(*User); ok {
@@ -101,6 +106,7 @@ func authSetup() error {
 		Authenticator: func(c *gin.Context) (interface{}, error) {
 			var loginVals Login
 			if err := c.ShouldBind(&loginVals); err != nil {
+				auditLogin(c, loginVals.Username, false, "missing credentials")
 				return "", jwt.ErrMissingLoginValues
 			}
 
@@ -110,11 +116,14 @@ func authSetup() error {
 					hasher := sha256.New()
 					hasher.Write([]byte(loginVals.Password))
 					if user.PasswordHash == hex.EncodeToString(hasher.Sum(nil)) {
+						auditLogin(c, user.Username, true, "")
 						return &user, nil
 					}
 				}
 			}
 
+			metricLoginFailures.Inc()
+			auditLogin(c, loginVals.Username, false, "wrong username or password")
 			return nil, jwt.ErrFailedAuthentication
 		},
 	})
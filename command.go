@@ -0,0 +1,72 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// The usage information printed for unknown commands
+const commandUsage = `Usage:
+  list-o-matic                 start the server
+  list-o-matic config check    validate the configuration file`
+
+// Run a maintenance command given on the command line instead of starting the server.
+// Returns the exit code of the process.
+func runCommand(args []string) int {
+	switch strings.Join(args, " ") {
+	case "config check":
+		return commandConfigCheck()
+	default:
+		fmt.Fprintln(os.Stderr, commandUsage)
+		return 2
+	}
+}
+
+// Load and validate the configuration, print all problems found
+func commandConfigCheck() int {
+	if err := cfgLoad(); err != nil {
+		printConfigError(err)
+		return 1
+	}
+
+	warnings, err := cfgValidate()
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "%s: warning: %s\n", cfgPath, warning)
+	}
+	if err != nil {
+		printConfigError(err)
+		return 1
+	}
+
+	fmt.Printf("%s: configuration is valid\n", cfgPath)
+	return 0
+}
+
+// Print each line of an error prefixed with the path of the configuration file
+func printConfigError(err error) {
+	for _, line := range strings.Split(err.Error(), "\n") {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", cfgPath, line)
+	}
+}
@@ -22,20 +22,106 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"gopkg.in/yaml.v2"
 )
 
+// The path of the configuration file
+const cfgPath = "config.yml"
+
+// The secret shipped in the example configuration, which must be changed in production
+const cfgDefaultSecret = "very secret"
+
+// The minimum length of the secret used to sign JSON Web Tokens
+const cfgMinSecretLength = 16
+
 // Config represents the configuration of this software
 type Config struct {
-	// Database contains paths to the JSON files containing users and talking lists
+	// Server contains settings for the HTTP server
+	Server struct {
+		// The address to listen on, e.g. ":8080" or "127.0.0.1:8080".
+		// A unix socket may be used by specifying "unix:" followed by the path of the socket.
+		Listen string `yaml:"listen"`
+
+		// TLS contains the paths to the certificate and key used for HTTPS.
+		// If both are empty, the server will use plain HTTP.
+		// The files are reloaded automatically when they change on disk.
+		TLS struct {
+			// The path to the PEM encoded certificate (chain)
+			CertPath string `yaml:"cert"`
+
+			// The path to the PEM encoded private key
+			KeyPath string `yaml:"key"`
+		} `yaml:"tls"`
+
+		// List of IP addresses or CIDRs of reverse proxies whose forwarding headers are trusted
+		TrustedProxies []string `yaml:"trusted_proxies"`
+
+		// The URL path all endpoints are served under, e.g. "/api"
+		BasePath string `yaml:"base_path"`
+
+		// The URL path below BasePath the API is served under, e.g. "/api".
+		// Has to be set if the frontend is served by this software.
+		APIPrefix string `yaml:"api_prefix"`
+
+		// The path to the directory containing the built frontend (with index.html).
+		// If empty, the frontend embedded at build time is served, if there is one.
+		FrontendDir string `yaml:"frontend_dir"`
+
+		// Time in seconds, in-flight requests may take to finish when the server is shut down.
+		// 0 does not wait for them, 10 is used if not given.
+		ShutdownTimeoutSeconds *int `yaml:"shutdown_timeout_seconds"`
+
+		// Flag, if running contributions should be stopped when the server is shut down
+		CloseContributionsOnShutdown bool `yaml:"close_contributions_on_shutdown"`
+	} `yaml:"server"`
+
+	// CORS contains the policy for cross-origin requests.
+	// If no origins are allowed, only requests from the same origin are accepted.
+	CORS struct {
+		// List of origins that may access the API, e.g. "https://lists.example.org"
+		AllowedOrigins []string `yaml:"allowed_origins"`
+
+		// List of HTTP methods that may be used in cross-origin requests
+		AllowedMethods []string `yaml:"allowed_methods"`
+
+		// List of HTTP headers that may be used in cross-origin requests
+		AllowedHeaders []string `yaml:"allowed_headers"`
+
+		// Flag, if credentials (cookies, HTTP authentication) may be sent in cross-origin requests
+		AllowCredentials bool `yaml:"allow_credentials"`
+
+		// Time in seconds, for which the result of a preflight request may be cached
+		MaxAgeSeconds int `yaml:"max_age_seconds"`
+	} `yaml:"cors"`
+
+	// Logging contains settings for the log output
+	Logging struct {
+		// The minimum level of log entries, one of "debug", "info", "warn" or "error"
+		Level string `yaml:"level"`
+
+		// The format of log entries, either "text" or "json"
+		Format string `yaml:"format"`
+	} `yaml:"logging"`
+
+	// Database contains paths to the JSON files containing users and talking lists, and to the audit log
 	Database struct {
 		// The path to the JSON file containing the talking lists
 		TalkingListsPath string `yaml:"talking_lists"`
 
 		// The path to the JSON file containing the users
 		UsersPath string `yaml:"users"`
+
+		// The path to the audit log, entries are only ever appended to it
+		AuditLogPath string `yaml:"audit_log"`
 	} `yaml:"database"`
 
 	// Authentication contains settings for the authentication system using JSON Web Tokens
@@ -51,19 +137,211 @@ type Config struct {
 // Represents the configuration data of this software
 var cfg Config
 
+// cfgErrors aggregates all problems found while validating the configuration
+type cfgErrors []error
+
+func (errs cfgErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
 // This function tries to load the current configuration from 'config.yml'.
+// Unknown keys are rejected, so typos do not go unnoticed.
 func cfgLoad() error {
-	file, err := os.Open("config.yml")
+	file, err := os.Open(cfgPath)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
 	decoder := yaml.NewDecoder(file)
+	decoder.SetStrict(true)
 	err = decoder.Decode(&cfg)
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		// Strip the Go type names from the messages, they are of no use to the user
+		var errs cfgErrors
+		for _, message := range typeErr.Errors {
+			message = strings.SplitN(message, " in type ", 2)[0]
+			errs = append(errs, errors.New(message))
+		}
+		return errs
+	}
 	if err != nil {
 		return err
 	}
 
+	if cfg.Server.Listen == "" {
+		cfg.Server.Listen = ":8080"
+	}
+	if cfg.Server.ShutdownTimeoutSeconds == nil {
+		defaultTimeout := 10
+		cfg.Server.ShutdownTimeoutSeconds = &defaultTimeout
+	}
+	if cfg.Logging.Level == "" {
+		cfg.Logging.Level = "info"
+	}
+	if cfg.Logging.Format == "" {
+		cfg.Logging.Format = "text"
+	}
+
 	return nil
 }
+
+// Validate the semantics of the loaded configuration.
+// All problems are collected and returned as cfgErrors, so they can be fixed at once.
+// Problems that do not prevent operation are returned as warnings.
+func cfgValidate() (warnings []string, err error) {
+	var errs cfgErrors
+
+	// Server
+	if strings.HasPrefix(cfg.Server.Listen, unixSocketPrefix) {
+		socketPath := strings.TrimPrefix(cfg.Server.Listen, unixSocketPrefix)
+		if socketPath == "" {
+			errs = append(errs, errors.New("server.listen: path of unix socket is empty"))
+		} else if err := checkDirWritable(filepath.Dir(socketPath)); err != nil {
+			errs = append(errs, fmt.Errorf("server.listen: cannot create unix socket: %w", err))
+		}
+	} else if _, _, err := net.SplitHostPort(cfg.Server.Listen); err != nil {
+		errs = append(errs, fmt.Errorf("server.listen: %w", err))
+	}
+
+	if (cfg.Server.TLS.CertPath == "") != (cfg.Server.TLS.KeyPath == "") {
+		errs = append(errs, errors.New("server.tls: both cert and key have to be set"))
+	} else if cfg.Server.TLS.CertPath != "" {
+		if err := checkFileReadable(cfg.Server.TLS.CertPath); err != nil {
+			errs = append(errs, fmt.Errorf("server.tls.cert: %w", err))
+		}
+		if err := checkFileReadable(cfg.Server.TLS.KeyPath); err != nil {
+			errs = append(errs, fmt.Errorf("server.tls.key: %w", err))
+		}
+	}
+
+	for _, proxy := range cfg.Server.TrustedProxies {
+		_, _, cidrErr := net.ParseCIDR(proxy)
+		if cidrErr != nil && net.ParseIP(proxy) == nil {
+			errs = append(errs, fmt.Errorf("server.trusted_proxies: %q is neither an IP address nor a CIDR", proxy))
+		}
+	}
+
+	if cfg.Server.BasePath != "" && (!strings.HasPrefix(cfg.Server.BasePath, "/") || strings.HasSuffix(cfg.Server.BasePath, "/")) {
+		errs = append(errs, errors.New("server.base_path: has to start and must not end with a slash"))
+	}
+
+	if cfg.Server.APIPrefix != "" && (!strings.HasPrefix(cfg.Server.APIPrefix, "/") || strings.HasSuffix(cfg.Server.APIPrefix, "/")) {
+		errs = append(errs, errors.New("server.api_prefix: has to start and must not end with a slash"))
+	}
+
+	if cfg.Server.FrontendDir != "" {
+		if err := checkFileReadable(filepath.Join(cfg.Server.FrontendDir, "index.html")); err != nil {
+			errs = append(errs, fmt.Errorf("server.frontend_dir: %w", err))
+		}
+	}
+
+	if (cfg.Server.FrontendDir != "" || embeddedFrontend != nil) && cfg.Server.APIPrefix == "" {
+		errs = append(errs, errors.New("server.api_prefix: has to be set when the frontend is served"))
+	}
+
+	if cfg.Server.ShutdownTimeoutSeconds != nil && *cfg.Server.ShutdownTimeoutSeconds < 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout_seconds: must not be negative"))
+	}
+
+	// CORS
+	for _, origin := range cfg.CORS.AllowedOrigins {
+		originUrl, err := url.Parse(origin)
+		if err != nil || originUrl.Scheme == "" || originUrl.Host == "" || originUrl.Path != "" {
+			errs = append(errs, fmt.Errorf("cors.allowed_origins: %q is not an origin like https://example.org", origin))
+		}
+	}
+
+	if cfg.CORS.MaxAgeSeconds < 0 {
+		errs = append(errs, errors.New("cors.max_age_seconds: must not be negative"))
+	}
+
+	// Logging
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Logging.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("logging.level: %q is not one of debug, info, warn or error", cfg.Logging.Level))
+	}
+
+	if cfg.Logging.Format != "text" && cfg.Logging.Format != "json" {
+		errs = append(errs, fmt.Errorf("logging.format: %q is neither text nor json", cfg.Logging.Format))
+	}
+
+	// Database
+	if cfg.Database.TalkingListsPath == "" {
+		errs = append(errs, errors.New("database.talking_lists: path is empty"))
+	} else if err := checkFileWritable(cfg.Database.TalkingListsPath); err != nil {
+		errs = append(errs, fmt.Errorf("database.talking_lists: %w", err))
+	}
+
+	if cfg.Database.UsersPath == "" {
+		errs = append(errs, errors.New("database.users: path is empty"))
+	} else if err := checkFileReadable(cfg.Database.UsersPath); err != nil {
+		errs = append(errs, fmt.Errorf("database.users: %w", err))
+	}
+
+	if cfg.Database.AuditLogPath == "" {
+		errs = append(errs, errors.New("database.audit_log: path is empty"))
+	} else if err := checkFileWritable(cfg.Database.AuditLogPath); err != nil {
+		errs = append(errs, fmt.Errorf("database.audit_log: %w", err))
+	}
+
+	// Authentication
+	switch {
+	case cfg.Authentication.Secret == "":
+		errs = append(errs, errors.New("authentication.secret: is empty"))
+	case cfg.Authentication.Secret == cfgDefaultSecret:
+		warnings = append(warnings, "authentication.secret: the default secret is used, anybody may forge logins")
+	case len(cfg.Authentication.Secret) < cfgMinSecretLength:
+		errs = append(errs, fmt.Errorf("authentication.secret: has to be at least %d characters long", cfgMinSecretLength))
+	}
+
+	if cfg.Authentication.TimeoutSeconds <= 0 {
+		errs = append(errs, errors.New("authentication.timeout_seconds: has to be positive"))
+	}
+
+	if len(errs) > 0 {
+		return warnings, errs
+	}
+
+	return warnings, nil
+}
+
+// Check if a file exists and may be read
+func checkFileReadable(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// Check if a file may be written.
+// If the file does not exist yet, check if it may be created.
+func checkFileWritable(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return checkDirWritable(filepath.Dir(path))
+	}
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// Check if files may be created in a directory
+func checkDirWritable(path string) error {
+	file, err := os.CreateTemp(path, ".list-o-matic-*")
+	if err != nil {
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		return fmt.Errorf("cannot create files in %s: %w", path, err)
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
@@ -0,0 +1,202 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Load the given YAML document as configuration file
+func loadTestConfig(t *testing.T, document string) error {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, cfgPath), []byte(document), 0600); err != nil {
+		t.Fatalf("Writing configuration failed: %v", err)
+	}
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getting working directory failed: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Changing working directory failed: %v", err)
+	}
+	defer os.Chdir(workDir)
+
+	cfg = Config{}
+	return cfgLoad()
+}
+
+// Set a configuration that passes validation
+func setValidTestConfig(t *testing.T) {
+	t.Helper()
+
+	cfg = Config{}
+	cfg.Server.Listen = ":8080"
+	cfg.Logging.Level = "info"
+	cfg.Logging.Format = "text"
+	cfg.Database.TalkingListsPath = filepath.Join(t.TempDir(), "talking_lists.json")
+	cfg.Database.UsersPath = "users.json.example"
+	cfg.Database.AuditLogPath = filepath.Join(t.TempDir(), "audit.log")
+	cfg.Authentication.Secret = "secret used for testing only"
+	cfg.Authentication.TimeoutSeconds = 60
+}
+
+func TestConfigLoadIsStrict(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		errors   []string
+	}{
+		{"valid", "server:\n  listen: \":9090\"\n", nil},
+		{"unknown key", "server:\n  listn: \":9090\"\n", []string{"field listn not found"}},
+		{"unknown section", "servers:\n  listen: \":9090\"\n", []string{"field servers not found"}},
+		{"several problems", "server:\n  listn: \":9090\"\n  shutdown_timeout_seconds: ten\n", []string{
+			"field listn not found",
+			"cannot unmarshal !!str `ten` into int",
+		}},
+	}
+
+	for _, test := range tests {
+		err := loadTestConfig(t, test.document)
+		if len(test.errors) == 0 {
+			if err != nil {
+				t.Errorf("%s: loading failed: %v", test.name, err)
+			}
+			continue
+		}
+
+		var errs cfgErrors
+		if !errors.As(err, &errs) {
+			t.Errorf("%s: expected cfgErrors, got %v", test.name, err)
+			continue
+		}
+		if len(errs) != len(test.errors) {
+			t.Errorf("%s: expected %d errors, got %v", test.name, len(test.errors), errs)
+			continue
+		}
+		for i, expected := range test.errors {
+			if !strings.Contains(errs[i].Error(), expected) || strings.Contains(errs[i].Error(), " in type ") {
+				t.Errorf("%s: expected an error containing %q, got %q", test.name, expected, errs[i])
+			}
+		}
+	}
+
+	// Defaults are only used for missing settings
+	if err := loadTestConfig(t, "server:\n  listen: \":9090\"\n"); err != nil {
+		t.Fatalf("Loading failed: %v", err)
+	}
+	if cfg.Server.Listen != ":9090" || *cfg.Server.ShutdownTimeoutSeconds != 10 || cfg.Logging.Level != "info" || cfg.Logging.Format != "text" {
+		t.Errorf("Unexpected configuration after loading: %+v", cfg)
+	}
+
+	// Not waiting for requests on shutdown is a valid choice
+	if err := loadTestConfig(t, "server:\n  shutdown_timeout_seconds: 0\n"); err != nil {
+		t.Fatalf("Loading failed: %v", err)
+	}
+	if *cfg.Server.ShutdownTimeoutSeconds != 0 {
+		t.Errorf("The shutdown timeout 0 was replaced by %d", *cfg.Server.ShutdownTimeoutSeconds)
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(*Config)
+		errors   []string
+		warnings []string
+	}{
+		{"valid", func(*Config) {}, nil, nil},
+		{"default secret", func(c *Config) {
+			c.Authentication.Secret = cfgDefaultSecret
+		}, nil, []string{"authentication.secret: the default secret is used"}},
+		{"short secret", func(c *Config) {
+			c.Authentication.Secret = "short"
+		}, []string{"authentication.secret: has to be at least"}, nil},
+		{"negative shutdown timeout", func(c *Config) {
+			timeout := -1
+			c.Server.ShutdownTimeoutSeconds = &timeout
+		}, []string{"server.shutdown_timeout_seconds: must not be negative"}, nil},
+		{"incomplete TLS", func(c *Config) {
+			c.Server.TLS.CertPath = "cert.pem"
+		}, []string{"server.tls: both cert and key have to be set"}, nil},
+		{"several problems", func(c *Config) {
+			c.Server.Listen = "8080"
+			c.Server.TrustedProxies = []string{"10.0.0.0/8", "proxy"}
+			c.Server.BasePath = "api/"
+			c.CORS.AllowedOrigins = []string{"https://lists.example.org/app"}
+			c.Logging.Level = "verbose"
+			c.Authentication.TimeoutSeconds = 0
+		}, []string{
+			"server.listen:",
+			"server.trusted_proxies: \"proxy\"",
+			"server.base_path:",
+			"cors.allowed_origins:",
+			"logging.level:",
+			"authentication.timeout_seconds:",
+		}, nil},
+	}
+
+	for _, test := range tests {
+		setValidTestConfig(t)
+		test.modify(&cfg)
+
+		warnings, err := cfgValidate()
+
+		if len(warnings) != len(test.warnings) {
+			t.Errorf("%s: expected %d warnings, got %v", test.name, len(test.warnings), warnings)
+		} else {
+			for i, expected := range test.warnings {
+				if !strings.HasPrefix(warnings[i], expected) {
+					t.Errorf("%s: expected a warning starting with %q, got %q", test.name, expected, warnings[i])
+				}
+			}
+		}
+
+		if len(test.errors) == 0 {
+			if err != nil {
+				t.Errorf("%s: validation failed: %v", test.name, err)
+			}
+			continue
+		}
+
+		var errs cfgErrors
+		if !errors.As(err, &errs) {
+			t.Errorf("%s: expected cfgErrors, got %v", test.name, err)
+			continue
+		}
+		if len(errs) != len(test.errors) {
+			t.Errorf("%s: expected %d errors, got %v", test.name, len(test.errors), errs)
+			continue
+		}
+		for i, expected := range test.errors {
+			if !strings.HasPrefix(errs[i].Error(), expected) {
+				t.Errorf("%s: expected an error starting with %q, got %q", test.name, expected, errs[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,194 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Add a second application to the group of a test list and return its UUID
+func addTestApplication(listUuid uuid.UUID, groupUuid uuid.UUID, name string) uuid.UUID {
+	applicationUuid := uuid.New()
+	groupEntry := lists[listUuid].Groups[groupUuid]
+	groupEntry.Applications[applicationUuid] = TalkingListApplication{Name: name, SubmittedAt: time.Now()}
+	groupEntry.setQueue(groupEntry.queue())
+	return applicationUuid
+}
+
+func TestUndoStart(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+	listUuid, groupUuid, aliceUuid := addTestList(VisibilityPublic)
+	bobUuid := addTestApplication(listUuid, groupUuid, "Bob")
+	listPath := "/v1/protected/list/" + listUuid.String()
+
+	if response := doRequest(router, http.MethodPost, listPath+"/undo", "", token); response.Code != http.StatusConflict {
+		t.Errorf("Undoing without a start or stop returned status %d, expected %d", response.Code, http.StatusConflict)
+	}
+
+	doRequest(router, http.MethodPost, listPath+"/start_contribution", `{"group_uuid": "`+groupUuid.String()+`", "application_uuid": "`+aliceUuid.String()+`"}`, token)
+	alice := lists[listUuid].CurrentContribution
+	doRequest(router, http.MethodPost, listPath+"/start_contribution", `{"group_uuid": "`+groupUuid.String()+`", "application_uuid": "`+bobUuid.String()+`"}`, token)
+
+	response := doRequest(router, http.MethodPost, listPath+"/undo", "", token)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Undoing the start returned status %d: %s", response.Code, response.Body.String())
+	}
+
+	listEntry := lists[listUuid]
+	current := listEntry.CurrentContribution
+	if !current.InProgress || current.Uuid != alice.Uuid || !current.EndTime.IsZero() || len(listEntry.PastContributions) != 0 {
+		t.Errorf("The contribution of Alice was not continued: %+v, past %+v", current, listEntry.PastContributions)
+	}
+	if bob, entryPresent := listEntry.Groups[groupUuid].Applications[bobUuid]; !entryPresent || bob.Name != "Bob" || bob.Position != 1 {
+		t.Errorf("The application of Bob was not restored: %+v", listEntry.Groups[groupUuid].Applications)
+	}
+
+	if response := doRequest(router, http.MethodPost, listPath+"/undo", "", token); response.Code != http.StatusConflict {
+		t.Errorf("Undoing twice returned status %d, expected %d", response.Code, http.StatusConflict)
+	}
+}
+
+func TestUndoStop(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+	listUuid, groupUuid, applicationUuid := addTestList(VisibilityPublic)
+	listPath := "/v1/protected/list/" + listUuid.String()
+
+	doRequest(router, http.MethodPost, listPath+"/start_contribution", `{"group_uuid": "`+groupUuid.String()+`", "application_uuid": "`+applicationUuid.String()+`"}`, token)
+	started := lists[listUuid].CurrentContribution
+	doRequest(router, http.MethodPost, listPath+"/stop_contribution", "", token)
+
+	response := doRequest(router, http.MethodPost, listPath+"/undo", "", token)
+	var contribution TalkingListContribution
+	if err := json.Unmarshal(response.Body.Bytes(), &contribution); err != nil || response.Code != http.StatusOK {
+		t.Fatalf("Undoing the stop returned status %d: %s", response.Code, response.Body.String())
+	}
+	if !contribution.InProgress || contribution.Uuid != started.Uuid || len(lists[listUuid].PastContributions) != 0 {
+		t.Errorf("The stopped contribution was not continued: %+v", contribution)
+	}
+
+	// A stop that the server did because the time ran out can not be undone
+	listEntry := lists[listUuid]
+	listEntry.CurrentContribution.TimeLimit = time.Second
+	autoStopContribution(listUuid, listEntry, time.Now())
+	if response := doRequest(router, http.MethodPost, listPath+"/undo", "", token); response.Code != http.StatusConflict {
+		t.Errorf("Undoing after the contribution was stopped by the server returned status %d, expected %d", response.Code, http.StatusConflict)
+	}
+}
+
+func TestStopWithoutContributionChangesNothing(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+	listUuid, groupUuid, applicationUuid := addTestList(VisibilityPublic)
+	listPath := "/v1/protected/list/" + listUuid.String()
+
+	doRequest(router, http.MethodPost, listPath+"/start_contribution", `{"group_uuid": "`+groupUuid.String()+`", "application_uuid": "`+applicationUuid.String()+`"}`, token)
+	doRequest(router, http.MethodPost, listPath+"/stop_contribution", "", token)
+	revision := lists[listUuid].Revision
+
+	if response := doRequest(router, http.MethodPost, listPath+"/stop_contribution", "", token); response.Code != http.StatusOK {
+		t.Errorf("Stopping without a running contribution returned status %d, expected %d", response.Code, http.StatusOK)
+	}
+	if lists[listUuid].Revision != revision {
+		t.Errorf("Stopping without a running contribution changed the revision from %d to %d", revision, lists[listUuid].Revision)
+	}
+
+	// The stop before is still the last action that can be undone
+	if response := doRequest(router, http.MethodPost, listPath+"/undo", "", token); response.Code != http.StatusOK || !lists[listUuid].CurrentContribution.InProgress {
+		t.Errorf("Undoing the last stop returned status %d: %s", response.Code, response.Body.String())
+	}
+}
+
+func TestCorrectPastContributions(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+	listUuid, groupUuid, applicationUuid := addTestList(VisibilityPublic)
+	listPath := "/v1/protected/list/" + listUuid.String()
+
+	doRequest(router, http.MethodPost, listPath+"/start_contribution", `{"group_uuid": "`+groupUuid.String()+`", "application_uuid": "`+applicationUuid.String()+`"}`, token)
+	doRequest(router, http.MethodPost, listPath+"/stop_contribution", "", token)
+	contributionPath := listPath + "/contribution/" + lists[listUuid].PastContributions[0].Uuid.String()
+
+	response := doRequest(router, http.MethodPost, contributionPath, `{"name": " ", "group_uuid": "`+uuid.NewString()+`", "end_time": "2000-01-01T00:00:00Z"}`, token)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("An invalid correction returned status %d, expected %d", response.Code, http.StatusBadRequest)
+	}
+	var body APIErrorResponse
+	json.Unmarshal(response.Body.Bytes(), &body)
+	if len(body.Error.Fields) != 3 {
+		t.Errorf("Expected three invalid fields, got %+v", body.Error.Fields)
+	}
+
+	response = doRequest(router, http.MethodPost, contributionPath, `{"name": "Alicia", "start_time": "2022-01-01T10:00:00Z", "end_time": "2022-01-01T10:02:30Z"}`, token)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Correcting the contribution returned status %d: %s", response.Code, response.Body.String())
+	}
+	contribution := lists[listUuid].PastContributions[0]
+	if contribution.Application.Name != "Alicia" || contribution.Duration != 150*time.Second || contribution.GroupUuid != groupUuid {
+		t.Errorf("The contribution was corrected to %+v", contribution)
+	}
+
+	// Corrections clear the last stop, it can no longer be undone
+	if response := doRequest(router, http.MethodPost, listPath+"/undo", "", token); response.Code != http.StatusConflict {
+		t.Errorf("Undoing after a correction returned status %d, expected %d", response.Code, http.StatusConflict)
+	}
+
+	if response := doRequest(router, http.MethodDelete, contributionPath, "", token); response.Code != http.StatusOK {
+		t.Errorf("Deleting the contribution returned status %d", response.Code)
+	}
+	if response := doRequest(router, http.MethodDelete, contributionPath, "", token); response.Code != http.StatusNotFound {
+		t.Errorf("Deleting the contribution twice returned status %d, expected %d", response.Code, http.StatusNotFound)
+	}
+
+	result := queryAudit(t, router, token, "?list="+listUuid.String())
+	if result.Total != 2 || result.Entries[0].Action != auditActionDeleteContribution || result.Entries[1].Action != auditActionEditContribution {
+		t.Fatalf("The corrections were recorded as %+v", result.Entries)
+	}
+	before, _ := result.Entries[1].Details["before"].(map[string]any)
+	after, _ := result.Entries[1].Details["after"].(map[string]any)
+	if before["application"].(map[string]any)["name"] != "Alice" || after["application"].(map[string]any)["name"] != "Alicia" {
+		t.Errorf("The correction was recorded as %+v", result.Entries[1].Details)
+	}
+}
+
+func TestOldContributionsGetUuids(t *testing.T) {
+	setupTestRouter(t)
+	listUuid := uuid.New()
+	database := `{"` + listUuid.String() + `": {"name": "Test", "past_contributions": [{"application": {"name": "Alice"}}]}}`
+	if err := os.WriteFile(cfg.Database.TalkingListsPath, []byte(database), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := setupDatabase(); err != nil {
+		t.Fatalf("Loading the database failed: %v", err)
+	}
+	if past := lists[listUuid].PastContributions; len(past) != 1 || past[0].Uuid == uuid.Nil {
+		t.Errorf("The contribution did not get a UUID: %+v", past)
+	}
+}
@@ -0,0 +1,66 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// Create the CORS middleware from the policy in the configuration.
+// Requests from the same origin are always allowed, requests from other origins
+// are only allowed if the origin is on the configured list.
+func corsSetup() gin.HandlerFunc {
+	allowedOrigins := make(map[string]bool)
+	for _, origin := range cfg.CORS.AllowedOrigins {
+		allowedOrigins[origin] = true
+	}
+
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOriginFunc = func(origin string) bool {
+		if !allowedOrigins[origin] {
+			slog.Warn("Rejected cross-origin request", "origin", origin)
+			return false
+		}
+		return true
+	}
+	corsConfig.AllowCredentials = cfg.CORS.AllowCredentials
+
+	if len(cfg.CORS.AllowedMethods) > 0 {
+		corsConfig.AllowMethods = cfg.CORS.AllowedMethods
+	}
+
+	if len(cfg.CORS.AllowedHeaders) > 0 {
+		corsConfig.AllowHeaders = cfg.CORS.AllowedHeaders
+	} else {
+		corsConfig.AddAllowHeaders("Authorization")
+	}
+
+	if cfg.CORS.MaxAgeSeconds > 0 {
+		corsConfig.MaxAge = time.Duration(cfg.CORS.MaxAgeSeconds) * time.Second
+	}
+
+	return cors.New(corsConfig)
+}
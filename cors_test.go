@@ -0,0 +1,79 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Create a router that only consists of the CORS middleware and a single route
+func setupCorsTestRouter(allowedOrigins ...string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	cfg = Config{}
+	cfg.CORS.AllowedOrigins = allowedOrigins
+
+	router := gin.New()
+	router.Use(corsSetup())
+	router.GET("/v1/public/list", func(context *gin.Context) {
+		context.Status(http.StatusOK)
+	})
+	return router
+}
+
+func TestCorsAllowsConfiguredOrigins(t *testing.T) {
+	router := setupCorsTestRouter("https://lists.example.org")
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://lists.example.org", true},
+		{"https://evil.example.com", false},
+		{"http://lists.example.org", false},
+	}
+
+	for _, test := range tests {
+		for _, method := range []string{http.MethodGet, http.MethodOptions} {
+			request := httptest.NewRequest(method, "/v1/public/list", nil)
+			request.Header.Set("Origin", test.origin)
+			if method == http.MethodOptions {
+				request.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			}
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			allowOrigin := recorder.Header().Get("Access-Control-Allow-Origin")
+			if test.allowed && allowOrigin != test.origin {
+				t.Errorf("%s from the configured origin %s returned Access-Control-Allow-Origin %q", method, test.origin, allowOrigin)
+			}
+			if !test.allowed && allowOrigin != "" {
+				t.Errorf("%s from the foreign origin %s returned Access-Control-Allow-Origin %q", method, test.origin, allowOrigin)
+			}
+		}
+	}
+}
@@ -22,6 +22,13 @@
 package main
 
 import (
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
@@ -29,18 +36,131 @@ import (
 // Can be saved and/or retrieved from disk.
 var lists map[uuid.UUID]TalkingList
 
+// Returned when writing the database is refused, because the existing one could not be loaded
+var errDatabaseNotLoaded = errors.New("the existing database could not be loaded and is not overwritten")
+
+// Protects the talking lists from concurrent access.
+// Copies of a list share its maps, which are modified in place. Therefore anything taken
+// from the lists has to be encoded (e.g. as JSON) before the mutex is released.
+var listsMutex sync.Mutex
+
+// Middleware that serializes access to the talking lists, so a request
+// never sees or writes a partially updated database.
+func lockDatabase() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		listsMutex.Lock()
+		defer listsMutex.Unlock()
+
+		context.Next()
+	}
+}
+
 // Initialize this pseudo database and try to read entries from file
 func setupDatabase() error {
 	lists = make(map[uuid.UUID]TalkingList)
-	return readListFromFile()
+	err := readListFromFile()
+
+	// A database that does not exist yet is fine, it will be created on the first write
+	databaseStatus.mutex.Lock()
+	databaseStatus.loaded = err == nil || errors.Is(err, os.ErrNotExist)
+	databaseStatus.mutex.Unlock()
+
+	return err
+}
+
+// Channels that are closed on the next change of a talking list, by the UUID of the list.
+// Protected by listsMutex.
+var listChangeWaiters = make(map[uuid.UUID]chan struct{})
+
+// Store a modified talking list, increase its revision and write the database to disk.
+// listsMutex has to be held.
+func storeList(listUuid uuid.UUID, listEntry *TalkingList) {
+	listEntry.Revision++
+	lists[listUuid] = *listEntry
+	dumpListToFile()
+	notifyListChanged(listUuid)
+}
+
+// Delete a talking list and write the database to disk.
+// listsMutex has to be held.
+func removeList(listUuid uuid.UUID) {
+	delete(lists, listUuid)
+	dumpListToFile()
+	notifyListChanged(listUuid)
 }
 
-// Dump the current database from RAM to disk
+// Get a channel that is closed on the next change of a talking list.
+// listsMutex has to be held.
+func listChanged(listUuid uuid.UUID) <-chan struct{} {
+	waiter, present := listChangeWaiters[listUuid]
+	if !present {
+		waiter = make(chan struct{})
+		listChangeWaiters[listUuid] = waiter
+	}
+	return waiter
+}
+
+// Wake up everybody waiting for a change of a talking list
+func notifyListChanged(listUuid uuid.UUID) {
+	if waiter, present := listChangeWaiters[listUuid]; present {
+		close(waiter)
+		delete(listChangeWaiters, listUuid)
+	}
+}
+
+// Dump the current database from RAM to disk.
+// If the existing database could not be loaded, it is kept, so it can be repaired.
 func dumpListToFile() error {
-	return dumpJsonToFile(&lists, cfg.Database.TalkingListsPath)
+	databaseStatus.mutex.Lock()
+	loaded := databaseStatus.loaded
+	databaseStatus.mutex.Unlock()
+	if !loaded {
+		slog.Error("Failed to write database", "error", errDatabaseNotLoaded)
+		return errDatabaseNotLoaded
+	}
+
+	start := time.Now()
+	err := dumpJsonToFile(&lists, cfg.Database.TalkingListsPath)
+	metricPersistenceDuration.Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		metricPersistenceFailures.Inc()
+		slog.Error("Failed to write database", "error", err)
+	}
+
+	databaseStatus.mutex.Lock()
+	databaseStatus.lastWriteErr = err
+	databaseStatus.mutex.Unlock()
+
+	return err
 }
 
 // Read the current list from disk to RAM
 func readListFromFile() error {
-	return parseJsonFromFile(&lists, cfg.Database.TalkingListsPath)
+	if err := parseJsonFromFile(&lists, cfg.Database.TalkingListsPath); err != nil {
+		return err
+	}
+
+	for listUuid, listEntry := range lists {
+		listEntry.assignContributionUuids()
+		lists[listUuid] = listEntry
+	}
+	return nil
+}
+
+// Flush the database to disk before the application exits.
+// If requested, running contributions are stopped before.
+func closeDatabase(closeContributions bool) error {
+	listsMutex.Lock()
+	defer listsMutex.Unlock()
+
+	if closeContributions {
+		now := time.Now()
+		for listUuid, listEntry := range lists {
+			listEntry.finishCurrentContribution(now)
+			lists[listUuid] = listEntry
+		}
+	}
+
+	return dumpListToFile()
 }
@@ -0,0 +1,114 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v2"
+)
+
+// The OpenAPI document describing all endpoints, embedded at build time
+//
+//go:embed openapi.yaml
+var openapiSource []byte
+
+// The page rendering the OpenAPI document, embedded at build time
+//
+//go:embed docs.html
+var docsPage []byte
+
+// Parse the OpenAPI document into a structure that may be encoded as JSON.
+// The server URL is set according to the configuration.
+func openapiDocument() (map[string]interface{}, error) {
+	var document map[interface{}]interface{}
+	if err := yaml.Unmarshal(openapiSource, &document); err != nil {
+		return nil, err
+	}
+
+	converted, ok := convertYamlMaps(document).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("OpenAPI document is not a mapping")
+	}
+
+	serverUrl := cfg.Server.BasePath + cfg.Server.APIPrefix
+	if serverUrl == "" {
+		serverUrl = "/"
+	}
+	converted["servers"] = []interface{}{
+		map[string]interface{}{"url": serverUrl},
+	}
+
+	return converted, nil
+}
+
+// The YAML decoder returns maps with arbitrary keys, which cannot be encoded as JSON.
+// Recursively convert them to maps with string keys.
+func convertYamlMaps(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for key, entry := range value {
+			converted[fmt.Sprint(key)] = convertYamlMaps(entry)
+		}
+		return converted
+	case []interface{}:
+		for i, entry := range value {
+			value[i] = convertYamlMaps(entry)
+		}
+		return value
+	default:
+		return value
+	}
+}
+
+// Register the endpoints serving the API documentation
+func setupDocsRoutes(router *gin.RouterGroup) error {
+	document, err := openapiDocument()
+	if err != nil {
+		return err
+	}
+
+	// The YAML document is served as written, so the order of entries is kept
+	servers, err := yaml.Marshal(map[string]interface{}{"servers": document["servers"]})
+	if err != nil {
+		return err
+	}
+	documentYaml := append(append(openapiSource[:len(openapiSource):len(openapiSource)], '\n'), servers...)
+
+	router.GET("/openapi.json", func(context *gin.Context) {
+		context.JSON(http.StatusOK, document)
+	})
+
+	router.GET("/openapi.yaml", func(context *gin.Context) {
+		context.Data(http.StatusOK, "application/yaml", documentYaml)
+	})
+
+	router.GET("/docs", func(context *gin.Context) {
+		context.Data(http.StatusOK, "text/html; charset=utf-8", docsPage)
+	})
+
+	return nil
+}
@@ -0,0 +1,77 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Matches path parameters in gin routes, e.g. ":uuid"
+var ginPathParameter = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// Every registered route has to be described in openapi.yaml and vice versa
+func TestAllRoutesDocumented(t *testing.T) {
+	router := setupTestRouter(t)
+
+	document, err := openapiDocument()
+	if err != nil {
+		t.Fatalf("Parsing OpenAPI document failed: %v", err)
+	}
+
+	paths, ok := document["paths"].(map[string]interface{})
+	if !ok {
+		t.Fatal("OpenAPI document has no paths")
+	}
+
+	methods := []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	documented := make(map[string]bool)
+	for path, item := range paths {
+		operations, ok := item.(map[string]interface{})
+		if !ok {
+			t.Errorf("Path %s in OpenAPI document is not a mapping", path)
+			continue
+		}
+		for _, method := range methods {
+			if _, present := operations[strings.ToLower(method)]; present {
+				documented[method+" "+path] = true
+			}
+		}
+	}
+
+	registered := make(map[string]bool)
+	for _, route := range router.Routes() {
+		key := route.Method + " " + ginPathParameter.ReplaceAllString(route.Path, "{$1}")
+		registered[key] = true
+		if !documented[key] {
+			t.Errorf("Route %s is not documented in openapi.yaml", key)
+		}
+	}
+
+	for key := range documented {
+		if !registered[key] {
+			t.Errorf("Route %s is documented in openapi.yaml, but not registered", key)
+		}
+	}
+}
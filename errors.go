@@ -0,0 +1,136 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Codes identifying the kind of an error in APIError
+const (
+	errorCodeInvalidParameter = "invalid_parameter"
+	errorCodeInvalidBody      = "invalid_body"
+	errorCodeUnauthorized     = "unauthorized"
+	errorCodeForbidden        = "forbidden"
+	errorCodeNotFound         = "not_found"
+	errorCodeConflict         = "conflict"
+	errorCodeUnknownCommand   = "unknown_command"
+	errorCodeInternal         = "internal_error"
+)
+
+// APIFieldError describes a problem with a single field of a request
+type APIFieldError struct {
+	// Name of the field (JSON key, path or query parameter)
+	Field string `json:"field"`
+
+	// Description of the problem
+	Message string `json:"message"`
+}
+
+// APIError describes why a request failed
+type APIError struct {
+	// Machine readable kind of the error, e.g. "not_found"
+	Code string `json:"code"`
+
+	// Human readable description of the error
+	Message string `json:"message"`
+
+	// Problems with single fields of the request, if any
+	Fields []APIFieldError `json:"fields,omitempty"`
+}
+
+func (err *APIError) Error() string {
+	return err.Message
+}
+
+// APIErrorResponse is the body of every response to a failed request
+type APIErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+func init() {
+	// Report the JSON names of fields in validation errors, not the names of the Go struct fields
+	if validate, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+		validate.RegisterValidation("visibility", func(field validator.FieldLevel) bool {
+			visibility, ok := field.Field().Interface().(TalkingListVisibility)
+			return ok && visibility.valid()
+		})
+	}
+}
+
+// Abort the request and respond with an error body
+func abortWithError(context *gin.Context, status int, code string, message string, fields ...APIFieldError) {
+	context.AbortWithStatusJSON(status, APIErrorResponse{
+		Error: APIError{
+			Code:    code,
+			Message: message,
+			Fields:  fields,
+		},
+	})
+}
+
+// Abort the request because the request body could not be bound,
+// reporting each invalid field separately
+func abortWithBindError(context *gin.Context, err error) {
+	apiError := bindErrorToAPIError(err)
+	abortWithError(context, http.StatusBadRequest, apiError.Code, apiError.Message, apiError.Fields...)
+}
+
+// Describe why a request body could not be bound or validated
+func bindErrorToAPIError(err error) APIError {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return APIError{Code: errorCodeInvalidBody, Message: err.Error()}
+	}
+
+	fields := make([]APIFieldError, 0, len(validationErrors))
+	for _, fieldError := range validationErrors {
+		message := "failed validation: " + fieldError.Tag()
+		switch fieldError.Tag() {
+		case "required":
+			message = "is required"
+		case "visibility":
+			message = "must be 0 (private), 1 (unlisted) or 2 (public)"
+		case "min":
+			message = "must be at least " + fieldError.Param()
+		case "oneof":
+			message = "must be one of " + strings.ReplaceAll(fieldError.Param(), " ", ", ")
+		}
+		fields = append(fields, APIFieldError{Field: fieldError.Field(), Message: message})
+	}
+
+	return APIError{Code: errorCodeInvalidBody, Message: "The request body is invalid", Fields: fields}
+}
@@ -0,0 +1,408 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Types of events sent to subscribers of a talking list
+const (
+	eventSnapshot               = "snapshot"
+	eventApplicationCreated     = "application_created"
+	eventApplicationDeleted     = "application_deleted"
+	eventApplicationsReordered  = "applications_reordered"
+	eventContributionStarted    = "contribution_started"
+	eventContributionStopped    = "contribution_stopped"
+	eventContributionWarning    = "contribution_warning"
+	eventContributionPaused     = "contribution_paused"
+	eventContributionResumed    = "contribution_resumed"
+	eventContributionUndone     = "contribution_undone"
+	eventContributionUpdated    = "contribution_updated"
+	eventContributionDeleted    = "contribution_deleted"
+	eventPastContributionsReset = "past_contributions_reset"
+	eventGroupCreated           = "group_created"
+	eventGroupDeleted           = "group_deleted"
+	eventVisibilityChanged      = "visibility_changed"
+	eventSpeakingTimeChanged    = "speaking_time_changed"
+	eventQueuePolicyChanged     = "queue_policy_changed"
+	eventAgendaChanged          = "agenda_changed"
+	eventMotionCreated          = "motion_created"
+	eventMotionOpened           = "motion_opened"
+	eventMotionClosed           = "motion_closed"
+	eventMotionDeleted          = "motion_deleted"
+	eventListDeleted            = "list_deleted"
+)
+
+const (
+	// Number of past events per list kept to resume interrupted streams
+	eventHistorySize = 256
+
+	// Number of events buffered per subscriber, slower subscribers are disconnected
+	eventSubscriberBuffer = 64
+
+	// Interval of comments sent to keep idle streams from being closed by proxies
+	eventKeepAliveInterval = 30 * time.Second
+
+	// Time in milliseconds after which clients should reconnect to a closed stream
+	eventRetryMilliseconds = 3000
+)
+
+// ListEvent represents a change of a talking list
+type ListEvent struct {
+	// Sequence number of the event, unique within its list
+	Id uint64
+
+	// Type of the event, one of the event constants
+	Type string
+
+	// Data of the event, encoded as JSON
+	Data json.RawMessage
+}
+
+// ApplicationEvent is the data of events about an application
+type ApplicationEvent struct {
+	// The UUID of the group the application belongs to
+	GroupUuid uuid.UUID `json:"group_uuid"`
+
+	// The UUID of the application
+	ApplicationUuid uuid.UUID `json:"application_uuid"`
+
+	// The application, if it was created
+	Application *TalkingListApplication `json:"application,omitempty"`
+}
+
+// QueueEvent is the data of the event sent when the queue of a group was reordered
+type QueueEvent struct {
+	// The UUID of the group
+	GroupUuid uuid.UUID `json:"group_uuid"`
+
+	// The UUIDs of all applications of the group in their new order
+	Order []uuid.UUID `json:"order"`
+}
+
+// UndoEvent is the data of the event sent when the last start or stop of a contribution was undone
+type UndoEvent struct {
+	// The action that was undone, "start_contribution" or "stop_contribution"
+	Action string `json:"action"`
+
+	// The current contribution after undoing the action
+	CurrentContribution TalkingListContribution `json:"current_contribution"`
+
+	// The past contribution that became the current one again, if any
+	ReopenedContributionUuid *uuid.UUID `json:"reopened_contribution_uuid,omitempty"`
+}
+
+// ContributionEvent is the data of the event sent when a past contribution was deleted
+type ContributionEvent struct {
+	// The UUID of the contribution
+	ContributionUuid uuid.UUID `json:"contribution_uuid"`
+}
+
+// GroupEvent is the data of events about a group
+type GroupEvent struct {
+	// The UUID of the group
+	GroupUuid uuid.UUID `json:"group_uuid"`
+
+	// The group, if it was created
+	Group *TalkingListGroup `json:"group,omitempty"`
+}
+
+// MotionEvent is the data of events about a motion
+type MotionEvent struct {
+	// The UUID of the motion
+	MotionUuid uuid.UUID `json:"motion_uuid"`
+
+	// The motion, unless it was deleted
+	Motion *TalkingListMotion `json:"motion,omitempty"`
+}
+
+// VisibilityEvent is the data of the event sent when the visibility of a list changes
+type VisibilityEvent struct {
+	// The new visibility of the list
+	Visibility TalkingListVisibility `json:"visibility"`
+}
+
+// SpeakingTimeEvent is the data of the event sent when speaking time limits change.
+// Either the limits of the list or the limit of a single group are set.
+type SpeakingTimeEvent struct {
+	// The new limits of the list
+	SpeakingTime *TalkingListSpeakingTime `json:"speaking_time,omitempty"`
+
+	// The UUID of the group whose limit changed
+	GroupUuid *uuid.UUID `json:"group_uuid,omitempty"`
+
+	// The new limit of the group
+	SpeakingTimeLimit *time.Duration `json:"speaking_time_limit,omitempty"`
+}
+
+// The events of a single talking list and those who listen to them
+type listEventStream struct {
+	// Id of the latest event
+	lastId uint64
+
+	// The latest events, oldest first
+	history []ListEvent
+
+	// Channels of all subscribers
+	subscribers map[chan ListEvent]struct{}
+}
+
+// All event streams by the UUID of their list
+var listEvents = struct {
+	mutex   sync.Mutex
+	streams map[uuid.UUID]*listEventStream
+	closed  bool
+}{streams: make(map[uuid.UUID]*listEventStream)}
+
+// Get the event stream of a list, creating it if necessary.
+// listEvents.mutex has to be held.
+func listEventStreamFor(listUuid uuid.UUID) *listEventStream {
+	stream, present := listEvents.streams[listUuid]
+	if !present {
+		stream = &listEventStream{subscribers: make(map[chan ListEvent]struct{})}
+		listEvents.streams[listUuid] = stream
+	}
+	return stream
+}
+
+// Send an event to all subscribers of a list and keep it for resuming streams.
+// listsMutex has to be held.
+func publishListEvent(listUuid uuid.UUID, eventType string, data any) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		slog.Error("Failed to encode event", "list", listUuid, "event", eventType, "error", err)
+		return
+	}
+
+	listEvents.mutex.Lock()
+	defer listEvents.mutex.Unlock()
+
+	stream := listEventStreamFor(listUuid)
+	stream.lastId++
+	event := ListEvent{Id: stream.lastId, Type: eventType, Data: encoded}
+
+	stream.history = append(stream.history, event)
+	if len(stream.history) > eventHistorySize {
+		stream.history = stream.history[len(stream.history)-eventHistorySize:]
+	}
+
+	for events := range stream.subscribers {
+		select {
+		case events <- event:
+		default:
+			// The subscriber does not keep up, it may resume from the last event it received
+			delete(stream.subscribers, events)
+			close(events)
+		}
+	}
+
+	// Nothing will happen to a deleted list anymore
+	if eventType == eventListDeleted {
+		for events := range stream.subscribers {
+			close(events)
+		}
+		delete(listEvents.streams, listUuid)
+	}
+}
+
+// Subscribe to the events of a list.
+// If lastId is given, the events after it are returned, so they can be sent before any new event.
+// If they are not available anymore, resumed is false and the subscriber has to start over.
+// The returned channel is closed if the subscriber is disconnected.
+func subscribeListEvents(listUuid uuid.UUID, lastId *uint64) (events chan ListEvent, missed []ListEvent, currentId uint64, resumed bool) {
+	listEvents.mutex.Lock()
+	defer listEvents.mutex.Unlock()
+
+	events = make(chan ListEvent, eventSubscriberBuffer)
+	if listEvents.closed {
+		close(events)
+		return events, nil, 0, false
+	}
+
+	stream := listEventStreamFor(listUuid)
+	stream.subscribers[events] = struct{}{}
+
+	if lastId != nil && *lastId <= stream.lastId {
+		resumed = *lastId == stream.lastId || (len(stream.history) > 0 && stream.history[0].Id <= *lastId+1)
+		for _, event := range stream.history {
+			if resumed && event.Id > *lastId {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	return events, missed, stream.lastId, resumed
+}
+
+// Stop receiving the events of a list
+func unsubscribeListEvents(listUuid uuid.UUID, events chan ListEvent) {
+	listEvents.mutex.Lock()
+	defer listEvents.mutex.Unlock()
+
+	stream, present := listEvents.streams[listUuid]
+	if !present {
+		return
+	}
+	if _, subscribed := stream.subscribers[events]; subscribed {
+		delete(stream.subscribers, events)
+		close(events)
+	}
+}
+
+// Disconnect all subscribers, so the server can shut down without waiting for them
+func closeListEvents() {
+	listEvents.mutex.Lock()
+	defer listEvents.mutex.Unlock()
+
+	listEvents.closed = true
+	for _, stream := range listEvents.streams {
+		for events := range stream.subscribers {
+			close(events)
+		}
+		stream.subscribers = make(map[chan ListEvent]struct{})
+	}
+}
+
+// Register the endpoints streaming events.
+// They must not be registered with lockDatabase, as the database would be locked while streaming.
+func setupEventRoutes(public *gin.RouterGroup) {
+	public.GET("/list/:uuid/events", streamListEvents)
+}
+
+// Subscribe to the events of the list referenced in a request.
+// The list has to be accessible by the public, like for publicListAccess.
+// If the stream can not be resumed from the Last-Event-ID header, the events start with a snapshot of the list.
+func openListEventStream(context *gin.Context) (uuid.UUID, chan ListEvent, []ListEvent, bool) {
+	// The list must not change between taking the snapshot and subscribing
+	listsMutex.Lock()
+	defer listsMutex.Unlock()
+
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return uuid.Nil, nil, nil, false
+	}
+	if !listEntry.Visibility.accessible() {
+		abortWithError(context, http.StatusNotFound, errorCodeNotFound, "The talking list does not exist")
+		return uuid.Nil, nil, nil, false
+	}
+
+	var lastId *uint64
+	if value, err := strconv.ParseUint(context.GetHeader("Last-Event-ID"), 10, 64); err == nil {
+		lastId = &value
+	}
+
+	snapshot, err := json.Marshal(listEntry.response(time.Now()))
+	if err != nil {
+		abortWithError(context, http.StatusInternalServerError, errorCodeInternal, err.Error())
+		return uuid.Nil, nil, nil, false
+	}
+
+	events, missed, currentId, resumed := subscribeListEvents(listUuid, lastId)
+	if !resumed {
+		missed = []ListEvent{{Id: currentId, Type: eventSnapshot, Data: snapshot}}
+	}
+
+	return listUuid, events, missed, true
+}
+
+// Stream the events of a talking list as Server-Sent Events.
+// The stream ends when the list is deleted or becomes private.
+func streamListEvents(context *gin.Context) {
+	listUuid, events, initial, ok := openListEventStream(context)
+	if !ok {
+		return
+	}
+	defer unsubscribeListEvents(listUuid, events)
+
+	context.Header("Content-Type", "text/event-stream")
+	context.Header("Cache-Control", "no-cache")
+	context.Header("X-Accel-Buffering", "no")
+	context.Status(http.StatusOK)
+
+	// Send an event and report whether the stream should go on
+	send := func(event ListEvent, retry uint) bool {
+		err := sse.Encode(context.Writer, sse.Event{
+			Id:    strconv.FormatUint(event.Id, 10),
+			Event: event.Type,
+			Retry: retry,
+			Data:  event.Data,
+		})
+		if err != nil {
+			return false
+		}
+
+		switch event.Type {
+		case eventListDeleted:
+			return false
+		case eventVisibilityChanged:
+			var visibility VisibilityEvent
+			return json.Unmarshal(event.Data, &visibility) == nil && visibility.Visibility.accessible()
+		}
+		return true
+	}
+
+	for i, event := range initial {
+		retry := uint(0)
+		if i == 0 {
+			retry = eventRetryMilliseconds
+		}
+		if !send(event, retry) {
+			context.Writer.Flush()
+			return
+		}
+	}
+	context.Writer.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-context.Request.Context().Done():
+			return
+		case event, open := <-events:
+			if !open {
+				return
+			}
+			goOn := send(event, 0)
+			context.Writer.Flush()
+			if !goOn {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := context.Writer.WriteString(": keep-alive\n\n"); err != nil {
+				return
+			}
+			context.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,218 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// A Server-Sent Event as received by a client
+type receivedEvent struct {
+	Id   string
+	Type string
+	Data string
+}
+
+// Connect to the event stream of a list, optionally resuming after an event
+func openEventStream(t *testing.T, server *httptest.Server, listUuid uuid.UUID, lastEventId string) *bufio.Reader {
+	t.Helper()
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL+"/v1/public/list/"+listUuid.String()+"/events", nil)
+	if lastEventId != "" {
+		request.Header.Set("Last-Event-ID", lastEventId)
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	response, err := client.Do(request)
+	if err != nil {
+		t.Fatalf("Connecting to the event stream failed: %v", err)
+	}
+	t.Cleanup(func() { response.Body.Close() })
+
+	if response.StatusCode != http.StatusOK || response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("The event stream returned status %d with type %q", response.StatusCode, response.Header.Get("Content-Type"))
+	}
+	return bufio.NewReader(response.Body)
+}
+
+// Read the next event from a stream, returns io.EOF if the stream ended
+func readEvent(reader *bufio.Reader) (receivedEvent, error) {
+	var event receivedEvent
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return event, err
+		}
+
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			if event.Type != "" {
+				return event, nil
+			}
+		case strings.HasPrefix(line, "id:"):
+			event.Id = strings.TrimPrefix(line, "id:")
+		case strings.HasPrefix(line, "event:"):
+			event.Type = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			event.Data = strings.TrimPrefix(line, "data:")
+		}
+	}
+}
+
+// Read the next event and check its type
+func expectEvent(t *testing.T, reader *bufio.Reader, eventType string) receivedEvent {
+	t.Helper()
+
+	event, err := readEvent(reader)
+	if err != nil {
+		t.Fatalf("Reading %s event failed: %v", eventType, err)
+	}
+	if event.Type != eventType {
+		t.Fatalf("Received %s event %s, expected %s", event.Type, event.Data, eventType)
+	}
+	return event
+}
+
+func TestEventsStreamChanges(t *testing.T) {
+	router := setupTestRouter(t)
+	server := newTestServer(t, router)
+
+	token := login(t, router)
+	listUuid, groupUuid, applicationUuid := addTestList(VisibilityUnlisted)
+	listPath := "/v1/protected/list/" + listUuid.String()
+	stream := openEventStream(t, server, listUuid, "")
+
+	snapshot := expectEvent(t, stream, eventSnapshot)
+	var list TalkingList
+	if err := json.Unmarshal([]byte(snapshot.Data), &list); err != nil || list.Name != "Test" {
+		t.Errorf("The snapshot contains %s", snapshot.Data)
+	}
+
+	groupPath := "/v1/public/list/" + listUuid.String() + "/group/" + groupUuid.String()
+	doRequest(router, http.MethodPost, groupPath+"/application", `{"name": "Bob"}`, "")
+	created := expectEvent(t, stream, eventApplicationCreated)
+	var application ApplicationEvent
+	if err := json.Unmarshal([]byte(created.Data), &application); err != nil ||
+		application.GroupUuid != groupUuid || application.Application == nil || application.Application.Name != "Bob" {
+		t.Errorf("The created application was sent as %s", created.Data)
+	}
+
+	start := `{"group_uuid": "` + groupUuid.String() + `", "application_uuid": "` + applicationUuid.String() + `"}`
+	doRequest(router, http.MethodPost, listPath+"/start_contribution", start, token)
+	expectEvent(t, stream, eventApplicationDeleted)
+	expectEvent(t, stream, eventContributionStarted)
+
+	doRequest(router, http.MethodPost, listPath+"/stop_contribution", "", token)
+	expectEvent(t, stream, eventContributionStopped)
+
+	doRequest(router, http.MethodPost, listPath+"/visibility", `{"new_visibility": 0}`, token)
+	expectEvent(t, stream, eventVisibilityChanged)
+	if event, err := readEvent(stream); err != io.EOF {
+		t.Errorf("The stream of a private list did not end, received %+v, %v", event, err)
+	}
+}
+
+func TestEventsResumeAfterLastEventId(t *testing.T) {
+	router := setupTestRouter(t)
+	server := newTestServer(t, router)
+
+	listUuid, groupUuid, _ := addTestList(VisibilityPublic)
+	groupPath := "/v1/public/list/" + listUuid.String() + "/group/" + groupUuid.String()
+	for _, name := range []string{"Bob", "Carol", "Dave"} {
+		doRequest(router, http.MethodPost, groupPath+"/application", `{"name": "`+name+`"}`, "")
+	}
+
+	stream := openEventStream(t, server, listUuid, "1")
+	for _, id := range []string{"2", "3"} {
+		if event := expectEvent(t, stream, eventApplicationCreated); event.Id != id {
+			t.Errorf("Resumed with event %s, expected %s", event.Id, id)
+		}
+	}
+
+	// Unknown events can not be resumed from, e.g. after a restart of the server
+	stream = openEventStream(t, server, listUuid, "1000")
+	if event := expectEvent(t, stream, eventSnapshot); event.Id != "3" {
+		t.Errorf("The snapshot has ID %s, expected 3", event.Id)
+	}
+}
+
+func TestEventsEndWhenListIsDeleted(t *testing.T) {
+	router := setupTestRouter(t)
+	server := newTestServer(t, router)
+
+	token := login(t, router)
+	listUuid, _, _ := addTestList(VisibilityPublic)
+	stream := openEventStream(t, server, listUuid, "")
+	expectEvent(t, stream, eventSnapshot)
+
+	doRequest(router, http.MethodDelete, "/v1/protected/list/"+listUuid.String(), "", token)
+	expectEvent(t, stream, eventListDeleted)
+	if event, err := readEvent(stream); err != io.EOF {
+		t.Errorf("The stream of a deleted list did not end, received %+v, %v", event, err)
+	}
+}
+
+func TestEventsAreStreamedWhileListChanges(t *testing.T) {
+	router := setupTestRouter(t)
+	server := newTestServer(t, router)
+
+	token := login(t, router)
+	listUuid, groupUuid, _ := addTestList(VisibilityPublic)
+	listPath := "/v1/protected/list/" + listUuid.String()
+	publicPath := "/v1/public/list/" + listUuid.String()
+
+	// Groups are created along with applications, so the maps in the history are modified as well
+	changesDone := make(chan struct{})
+	go func() {
+		defer close(changesDone)
+		for i := 0; i < 50; i++ {
+			response := doRequest(router, http.MethodPost, listPath+"/group", `{"name": "Gruppe"}`, token)
+			var group struct {
+				Uuid uuid.UUID `json:"uuid"`
+			}
+			json.Unmarshal(response.Body.Bytes(), &group)
+
+			for _, applicationGroupUuid := range []uuid.UUID{groupUuid, group.Uuid} {
+				doRequest(router, http.MethodPost, publicPath+"/group/"+applicationGroupUuid.String()+"/application", `{"name": "Alice"}`, "")
+			}
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		for _, lastEventId := range []string{"", "1"} {
+			if _, err := readEvent(openEventStream(t, server, listUuid, lastEventId)); err != nil {
+				t.Fatalf("Reading the first event failed: %v", err)
+			}
+		}
+	}
+	<-changesDone
+}
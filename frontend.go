@@ -0,0 +1,91 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Get the file system containing the frontend to serve.
+// A configured directory takes precedence over the frontend embedded at build time.
+// Returns nil, if no frontend is to be served.
+func frontendFS() (fs.FS, error) {
+	if cfg.Server.FrontendDir != "" {
+		return os.DirFS(cfg.Server.FrontendDir), nil
+	}
+
+	if embeddedFrontend != nil {
+		return fs.Sub(embeddedFrontend, embeddedFrontendDir)
+	}
+
+	return nil, nil
+}
+
+// Create a handler that serves the single page application for all
+// requests below the base path that are not directed at the API.
+// Paths that do not refer to a file are answered with index.html,
+// so the router of the frontend can handle them.
+func frontendHandler(frontend fs.FS) gin.HandlerFunc {
+	basePath := cfg.Server.BasePath
+	fileServer := http.StripPrefix(basePath, http.FileServer(http.FS(frontend)))
+	apiPath := basePath + cfg.Server.APIPrefix
+
+	return func(context *gin.Context) {
+		requestPath := context.Request.URL.Path
+		if context.Request.Method != http.MethodGet && context.Request.Method != http.MethodHead ||
+			!(requestPath == basePath || strings.HasPrefix(requestPath, basePath+"/")) ||
+			requestPath == apiPath || strings.HasPrefix(requestPath, apiPath+"/") {
+			abortWithError(context, http.StatusNotFound, errorCodeNotFound, "The endpoint does not exist")
+			return
+		}
+
+		name := strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(requestPath, basePath)), "/")
+		if name != "" {
+			info, err := fs.Stat(frontend, name)
+			if err == nil && !info.IsDir() {
+				fileServer.ServeHTTP(context.Writer, context.Request)
+				return
+			}
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				abortWithError(context, http.StatusInternalServerError, errorCodeInternal, err.Error())
+				return
+			}
+		}
+
+		// The index.html is served directly, as the file server would redirect to the directory
+		index, err := fs.ReadFile(frontend, "index.html")
+		if err != nil {
+			abortWithError(context, http.StatusNotFound, errorCodeNotFound, "The frontend has no index.html")
+			return
+		}
+
+		context.Header("Cache-Control", "no-cache")
+		context.Data(http.StatusOK, "text/html; charset=utf-8", index)
+	}
+}
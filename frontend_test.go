@@ -0,0 +1,86 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFrontendIsServed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg = Config{}
+	cfg.Server.BasePath = "/app"
+	cfg.Server.APIPrefix = "/api"
+
+	frontend := fstest.MapFS{
+		"index.html":     {Data: []byte("<html>index</html>")},
+		"assets/main.js": {Data: []byte("console.log('main')")},
+	}
+	router := gin.New()
+	router.NoRoute(frontendHandler(frontend))
+
+	const index, asset, notFound = "<html>index</html>", "console.log('main')", "JSON 404"
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/app", index},
+		{http.MethodGet, "/app/", index},
+		{http.MethodGet, "/app/list/0b6d1a3c", index},
+		{http.MethodGet, "/app/assets", index},
+		{http.MethodGet, "/app/assets/main.js", asset},
+		{http.MethodHead, "/app/assets/main.js", ""},
+		{http.MethodGet, "/app/assets/../../../go.mod", index},
+		{http.MethodGet, "/app/api", notFound},
+		{http.MethodGet, "/app/api/v1/unknown", notFound},
+		{http.MethodGet, "/application/assets/main.js", notFound},
+		{http.MethodGet, "/assets/main.js", notFound},
+		{http.MethodPost, "/app/list", notFound},
+	}
+
+	for _, test := range tests {
+		request := httptest.NewRequest(test.method, "/", nil)
+		request.URL.Path = test.path
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		if test.body == notFound {
+			var body APIErrorResponse
+			if recorder.Code != http.StatusNotFound || json.Unmarshal(recorder.Body.Bytes(), &body) != nil || body.Error.Code != errorCodeNotFound {
+				t.Errorf("%s %s returned status %d with %q, expected a JSON error with status %d",
+					test.method, test.path, recorder.Code, recorder.Body.String(), http.StatusNotFound)
+			}
+			continue
+		}
+
+		if recorder.Code != http.StatusOK || recorder.Body.String() != test.body {
+			t.Errorf("%s %s returned status %d with %q, expected %q", test.method, test.path, recorder.Code, recorder.Body.String(), test.body)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// The HTTP header carrying the ID of a request
+const requestIdHeader = "X-Request-ID"
+
+// The key the request ID is stored under in the gin context
+const requestIdContextKey = "request_id"
+
+// Request IDs provided by clients or proxies are only accepted if they match this pattern
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Configure the default logger according to the configuration
+func loggingSetup() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Logging.Level)); err != nil {
+		return err
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch cfg.Logging.Format {
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, options)
+	default:
+		return fmt.Errorf("unknown log format %q", cfg.Logging.Format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// Log an error and exit the application
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// Middleware that assigns an ID to each request and echoes it in the response.
+// An ID provided by the client (e.g. a reverse proxy) is reused.
+func requestIdMiddleware() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		requestId := context.GetHeader(requestIdHeader)
+		if !requestIdPattern.MatchString(requestId) {
+			requestId = uuid.NewString()
+		}
+
+		context.Set(requestIdContextKey, requestId)
+		context.Header(requestIdHeader, requestId)
+
+		context.Next()
+	}
+}
+
+// Middleware that logs each request after it has been handled
+func requestLogMiddleware() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		start := time.Now()
+		context.Next()
+
+		requestLogger(context).Info("Request handled",
+			"method", context.Request.Method,
+			"path", context.Request.URL.Path,
+			"route", context.FullPath(),
+			"status", context.Writer.Status(),
+			"latency", time.Since(start),
+			"client_ip", context.ClientIP(),
+			"user", contextUsername(context),
+		)
+	}
+}
+
+// Get a logger for a request, which adds the request ID to every entry
+func requestLogger(context *gin.Context) *slog.Logger {
+	return slog.Default().With("request_id", context.GetString(requestIdContextKey))
+}
+
+// Get the name of the user who sent a request.
+// Returns an empty string for requests that were not authenticated.
+func contextUsername(context *gin.Context) string {
+	identity, exists := context.Get(authMiddleware.IdentityKey)
+	if !exists {
+		return ""
+	}
+
+	user, ok := identity.(*User)
+	if !ok || user == nil {
+		return ""
+	}
+
+	return user.Username
+}
+
+// Log an action that modifies a talking list, along with the user who did it
+func logListAction(context *gin.Context, listUuid uuid.UUID, action string, args ...any) {
+	username := contextUsername(context)
+	if username == "" {
+		username = "anonymous"
+	}
+
+	args = append([]any{"list", listUuid, "action", action, "user", username}, args...)
+	requestLogger(context).Info("List modified", args...)
+}
@@ -0,0 +1,64 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRequestIds(t *testing.T) {
+	router := setupTestRouter(t)
+
+	tests := []struct {
+		supplied string
+		echoed   bool
+	}{
+		{"", false},
+		{"3f2c9a1e-proxy.42", true},
+		{"contains spaces", false},
+		{strings.Repeat("a", 65), false},
+	}
+
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		if test.supplied != "" {
+			request.Header.Set(requestIdHeader, test.supplied)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		requestId := recorder.Header().Get(requestIdHeader)
+		if test.echoed && requestId != test.supplied {
+			t.Errorf("The request ID %q was not echoed, got %q", test.supplied, requestId)
+		}
+		if !test.echoed {
+			if _, err := uuid.Parse(requestId); err != nil {
+				t.Errorf("For the supplied request ID %q, %q was returned instead of a generated UUID", test.supplied, requestId)
+			}
+		}
+	}
+}
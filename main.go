@@ -23,53 +23,139 @@
 package main
 
 import (
-	"log"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	// Start by loading the configuration, fail if it does not exist
+	// Maintenance commands are run instead of the server
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
+	// Start by loading the configuration, fail if it does not exist or is invalid
 	if err := cfgLoad(); err != nil {
-		log.Fatal("Failed to load configuration file.")
+		fatal("Failed to load configuration file", "error", err)
+	}
+	warnings, err := cfgValidate()
+	for _, warning := range warnings {
+		slog.Warn("Problem in configuration", "warning", warning)
+	}
+	if err != nil {
+		fatal("Invalid configuration", "error", err)
+	}
+	if err := loggingSetup(); err != nil {
+		fatal("Setting up logging failed", "error", err)
 	}
 
 	// Then try to load the pseudo database of talking lists
 	if err := setupDatabase(); err != nil {
-		log.Print("Could not load an existing database, creating a new one.")
+		slog.Error("Could not load the existing database, changes will not be written to disk", "error", err)
 	}
 
 	// Release mode, comment out this line when developing
 	gin.SetMode(gin.ReleaseMode)
 
+	router, err := setupRouter()
+	if err != nil {
+		fatal("Setting up router failed", "error", err)
+	}
+
+	// Contributions are warned and stopped by the server, even if no moderator is connected
+	stopSpeakingTimeChecks := startSpeakingTimeChecks()
+
+	if err := serverRun(router); err != nil {
+		fatal("Failed to start web server", "error", err)
+	}
+	stopSpeakingTimeChecks()
+
+	// The server was shut down, make sure everything is on disk
+	if err := closeDatabase(cfg.Server.CloseContributionsOnShutdown); err != nil {
+		fatal("Failed to write database on shutdown", "error", err)
+	}
+	if err := auditClose(); err != nil {
+		slog.Error("Failed to close audit log", "error", err)
+	}
+	slog.Info("Database written, exiting")
+}
+
+// Create the router serving all endpoints (and the frontend) according to the configuration
+func setupRouter() (*gin.Engine, error) {
 	// Setup gin-gonic Library
 	router := gin.New()
-	router.Use(gin.Logger())
+	router.Use(requestIdMiddleware())
+	router.Use(requestLogMiddleware())
+	// Before the recovery middleware, so requests that panicked are counted with their status 500
+	router.Use(metricsMiddleware())
 	router.Use(gin.Recovery())
 
+	// Only trust forwarding headers of the configured reverse proxies
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid list of trusted proxies: %w", err)
+	}
+
 	// Modern browsers use CORS preflighting for requests to ensure higher
-	// security.
-	corsConfig := cors.DefaultConfig()
-	corsConfig.AllowAllOrigins = true
-	corsConfig.AddAllowHeaders("Authorization")
-	corsConfig.AddAllowHeaders("Content-Type")
-	router.Use(cors.New(corsConfig))
+	// security. Only the origins in the configuration are allowed.
+	router.Use(corsSetup())
 
 	// Setup authentication middleware
 	if err := authSetup(); err != nil {
-		log.Fatal("Setting up authentication subsystem failed.")
+		return nil, fmt.Errorf("setting up authentication subsystem failed: %w", err)
+	}
+
+	if err := auditSetup(); err != nil {
+		return nil, fmt.Errorf("opening audit log failed: %w", err)
 	}
-	router.POST("/login", authMiddleware.LoginHandler)
 
-	protected := router.Group("/protected")
-	public := router.Group("/public")
-	protected.Use(authMiddleware.MiddlewareFunc())
+	// All endpoints are served below the configured base path and API prefix
+	api := router.Group(cfg.Server.BasePath + cfg.Server.APIPrefix)
+	api.POST("/login", authMiddleware.LoginHandler)
+	setupMonitoringRoutes(api)
+	if err := setupDocsRoutes(api); err != nil {
+		return nil, fmt.Errorf("loading API documentation failed: %w", err)
+	}
+
+	v1 := api.Group("/v1")
+	v1.POST("/login", authMiddleware.LoginHandler)
 	{
+		protected := v1.Group("/protected", authMiddleware.MiddlewareFunc(), lockDatabase())
+		public := v1.Group("/public", lockDatabase())
 		setupRoutes(public, protected)
+
+		admin := v1.Group("/protected", authMiddleware.MiddlewareFunc(), requireAdmin())
+		setupAuditRoutes(admin)
+
+		// Streams and long polling lock the database on their own, only while they read from it
+		stream := v1.Group("/public")
+		setupEventRoutes(stream)
+		setupPollingRoutes(stream, v1.Group("/protected", authMiddleware.MiddlewareFunc()))
+		moderator := v1.Group("/protected", tokenFromQuery(), authMiddleware.MiddlewareFunc())
+		setupModeratorRoutes(moderator)
+	}
+
+	// The unversioned endpoints are kept for compatibility with older clients
+	{
+		protected := api.Group("/protected", authMiddleware.MiddlewareFunc(), lockDatabase())
+		public := api.Group("/public", lockDatabase())
+		setupLegacyRoutes(public, protected)
 	}
 
-	if err := router.Run(); err != nil {
-		log.Fatal("Failed to start web server")
+	// Serve the frontend for all other paths, if there is one
+	frontend, err := frontendFS()
+	if err != nil {
+		return nil, fmt.Errorf("opening frontend failed: %w", err)
+	}
+	if frontend != nil {
+		router.NoRoute(frontendHandler(frontend))
+	} else {
+		router.NoRoute(func(context *gin.Context) {
+			abortWithError(context, http.StatusNotFound, errorCodeNotFound, "The endpoint does not exist")
+		})
 	}
+
+	return router, nil
 }
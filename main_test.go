@@ -0,0 +1,138 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Load a configuration suitable for tests and create the router.
+// The database is written to a temporary directory.
+// The configuration may be adjusted by the given functions before the router is created.
+func setupTestRouter(t *testing.T, configure ...func(*Config)) *gin.Engine {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	cfg = Config{}
+	cfg.Database.TalkingListsPath = filepath.Join(t.TempDir(), "talking_lists.json")
+	cfg.Database.UsersPath = "users.json.example"
+	cfg.Database.AuditLogPath = filepath.Join(t.TempDir(), "audit.log")
+	cfg.Authentication.Secret = "secret used for testing only"
+	cfg.Authentication.TimeoutSeconds = 60
+	for _, configureFunc := range configure {
+		configureFunc(&cfg)
+	}
+
+	// The database does not exist yet, so an empty one is used
+	setupDatabase()
+
+	router, err := setupRouter()
+	if err != nil {
+		t.Fatalf("Setting up router failed: %v", err)
+	}
+
+	return router
+}
+
+// Start a server for tests that need real connections, like event streams and WebSockets.
+// On cleanup it waits for all handlers to return, so they do not outlive the test.
+func newTestServer(t *testing.T, router *gin.Engine) *httptest.Server {
+	t.Helper()
+
+	var handlers sync.WaitGroup
+	t.Cleanup(handlers.Wait)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		handlers.Add(1)
+		defer handlers.Done()
+		router.ServeHTTP(writer, request)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestRoutesAreServedBelowBasePath(t *testing.T) {
+	router := setupTestRouter(t, func(c *Config) {
+		c.Server.BasePath = "/lists"
+	})
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/lists/v1/public/list", http.StatusOK},
+		{"/lists/healthz", http.StatusOK},
+		{"/lists/public/list", http.StatusOK},
+		{"/v1/public/list", http.StatusNotFound},
+		{"/listsv1/public/list", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		if response := doRequest(router, http.MethodGet, test.path, "", ""); response.Code != test.status {
+			t.Errorf("GET %s returned status %d, expected %d", test.path, response.Code, test.status)
+		}
+	}
+
+	response := doRequest(router, http.MethodPost, "/lists/v1/login", `{"username": "admin", "password": "admin"}`, "")
+	if response.Code != http.StatusOK {
+		t.Errorf("Logging in below the base path returned status %d, expected %d", response.Code, http.StatusOK)
+	}
+}
+
+func TestClientIpFromTrustedProxies(t *testing.T) {
+	tests := []struct {
+		trustedProxies []string
+		clientIp       string
+	}{
+		{nil, "192.0.2.1"},
+		{[]string{"198.51.100.0/24"}, "192.0.2.1"},
+		{[]string{"192.0.2.1"}, "203.0.113.7"},
+		{[]string{"192.0.2.0/24"}, "203.0.113.7"},
+	}
+
+	for _, test := range tests {
+		router := setupTestRouter(t, func(c *Config) {
+			c.Server.TrustedProxies = test.trustedProxies
+		})
+		router.GET("/client_ip", func(context *gin.Context) {
+			context.String(http.StatusOK, context.ClientIP())
+		})
+
+		request := httptest.NewRequest(http.MethodGet, "/client_ip", nil)
+		request.RemoteAddr = "192.0.2.1:41234"
+		request.Header.Set("X-Forwarded-For", "203.0.113.7")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		if recorder.Body.String() != test.clientIp {
+			t.Errorf("With the trusted proxies %v, the client IP is %s, expected %s", test.trustedProxies, recorder.Body.String(), test.clientIp)
+		}
+	}
+}
@@ -22,17 +22,120 @@
 package main
 
 import (
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// TalkingListVisibility determines who may see a talking list
+type TalkingListVisibility int
+
+const (
+	// The list can only be seen by administrators
+	VisibilityPrivate TalkingListVisibility = 0
+
+	// The list can be seen by administrators and those who have a link
+	VisibilityUnlisted TalkingListVisibility = 1
+
+	// The list can be seen by everybody
+	VisibilityPublic TalkingListVisibility = 2
+)
+
+// Check whether the visibility is one of the known values
+func (visibility TalkingListVisibility) valid() bool {
+	return visibility >= VisibilityPrivate && visibility <= VisibilityPublic
+}
+
+// Check whether the list may be accessed through the public endpoints
+func (visibility TalkingListVisibility) accessible() bool {
+	return visibility == VisibilityUnlisted || visibility == VisibilityPublic
+}
+
+func (visibility TalkingListVisibility) String() string {
+	switch visibility {
+	case VisibilityPrivate:
+		return "private"
+	case VisibilityUnlisted:
+		return "unlisted"
+	case VisibilityPublic:
+		return "public"
+	default:
+		return "invalid"
+	}
+}
+
 // A TalkingListApplication represents an application to talk at an event.
 // It belongs to a TalkingListGroup and will be part of a TalkingListContribution once the person
 // is allowed to speak.
 type TalkingListApplication struct {
 	// Name of the person that wants to speak
 	Name string `json:"name" binding:"required"`
+
+	// The time when the application was submitted, set by the server
+	SubmittedAt time.Time `json:"submitted_at" binding:"-"`
+
+	// Position of the application in the queue of its group, starting at 1.
+	// Set by the server, zero once the application became a contribution.
+	Position int `json:"position,omitempty" binding:"-"`
+
+	// The category of the speaker for quota mode, one of the QuotaCategory constants
+	QuotaCategory string `json:"quota_category,omitempty" binding:"omitempty,oneof=flinta open"`
+
+	// The attendee who wants to speak, if known
+	AttendeeUuid *uuid.UUID `json:"attendee_uuid,omitempty" binding:"-"`
+
+	// The kind of the application, one of the ApplicationType constants, regular if not given
+	Type string `json:"type,omitempty" binding:"omitempty,oneof=regular direct_reply point_of_order"`
+}
+
+// Kinds of applications
+const (
+	// A regular application, called according to the queue policy
+	ApplicationTypeRegular = "regular"
+
+	// A direct reply to the current speaker
+	ApplicationTypeDirectReply = "direct_reply"
+
+	// A point of order (GO-Antrag)
+	ApplicationTypePointOfOrder = "point_of_order"
+)
+
+// Get the kind of the application, applications without one are regular
+func (application TalkingListApplication) applicationType() string {
+	if application.Type == "" {
+		return ApplicationTypeRegular
+	}
+	return application.Type
+}
+
+// Categories of speakers in quota mode
+const (
+	// FLINTA* speakers
+	QuotaCategoryFlinta = "flinta"
+
+	// All other speakers
+	QuotaCategoryOpen = "open"
+)
+
+// Get the quota category of the speaker, applications without one count as open
+func (application TalkingListApplication) quotaCategory() string {
+	if application.QuotaCategory == QuotaCategoryFlinta {
+		return QuotaCategoryFlinta
+	}
+	return QuotaCategoryOpen
+}
+
+// TalkingListQueuedApplication is an application along with its UUID,
+// used where applications are listed in the order of the queue
+type TalkingListQueuedApplication struct {
+	// The UUID of the application
+	Uuid uuid.UUID `json:"uuid"`
+
+	TalkingListApplication
+
+	// Indicates, if the application goes ahead of repeat speakers because the person has not spoken yet
+	Prioritised bool `json:"prioritised"`
 }
 
 // A TalkingListGroup represents a group of speakers at an event.
@@ -42,11 +145,65 @@ type TalkingListGroup struct {
 
 	// A list of TalkingListApplications belonging to said group
 	Applications map[uuid.UUID]TalkingListApplication `json:"applications" binding:"-"`
+
+	// Maximum speaking time of contributions of this group, overrides the limit of the list.
+	// Zero means that the limit of the list applies.
+	SpeakingTimeLimit time.Duration `json:"speaking_time_limit" binding:"min=0"`
+}
+
+// Get the UUIDs of the applications of the group in the order they will be called.
+// Applications without a position, e.g. from databases of older versions, are ordered by submission.
+func (group *TalkingListGroup) queue() []uuid.UUID {
+	order := make([]uuid.UUID, 0, len(group.Applications))
+	for applicationUuid := range group.Applications {
+		order = append(order, applicationUuid)
+	}
+
+	sort.Slice(order, func(i, j int) bool {
+		a, b := group.Applications[order[i]], group.Applications[order[j]]
+		if a.Position != b.Position {
+			return a.Position < b.Position
+		}
+		if !a.SubmittedAt.Equal(b.SubmittedAt) {
+			return a.SubmittedAt.Before(b.SubmittedAt)
+		}
+		return order[i].String() < order[j].String()
+	})
+
+	return order
+}
+
+// Number the applications of the group according to the given order,
+// which has to contain every application exactly once
+func (group *TalkingListGroup) setQueue(order []uuid.UUID) {
+	for index, applicationUuid := range order {
+		applicationEntry := group.Applications[applicationUuid]
+		applicationEntry.Position = index + 1
+		group.Applications[applicationUuid] = applicationEntry
+	}
+}
+
+// Get the applications of a group in the order of its queue
+func (list *TalkingList) queuedApplications(groupUuid uuid.UUID) []TalkingListQueuedApplication {
+	groupEntry := list.Groups[groupUuid]
+	queued := make([]TalkingListQueuedApplication, 0, len(groupEntry.Applications))
+	for _, applicationUuid := range groupEntry.queue() {
+		applicationEntry := groupEntry.Applications[applicationUuid]
+		queued = append(queued, TalkingListQueuedApplication{
+			Uuid:                   applicationUuid,
+			TalkingListApplication: applicationEntry,
+			Prioritised:            list.prioritised(applicationEntry),
+		})
+	}
+	return queued
 }
 
 // TalkingListContribution represents a contribution to the talking list.
 // It is created from a TalkingListApplication.
 type TalkingListContribution struct {
+	// The UUID of the contribution, set by the server when it starts
+	Uuid uuid.UUID `json:"uuid" binding:"-"`
+
 	// Indicates, if this contribution is currently active.
 	InProgress bool `json:"in_progress" binding:"-"`
 
@@ -56,6 +213,9 @@ type TalkingListContribution struct {
 	// The UUID of the group the Application belongs to
 	GroupUuid uuid.UUID `json:"group_uuid" binding:"-"`
 
+	// The agenda item that was current when the contribution started, if any
+	AgendaItemUuid *uuid.UUID `json:"agenda_item_uuid,omitempty" binding:"-"`
+
 	// The time when the contribution started
 	StartTime time.Time `json:"start_time" binding:"-"`
 
@@ -65,6 +225,74 @@ type TalkingListContribution struct {
 	// After the contribution is finished, this will contain the delta
 	// of StartTime and EndTime
 	Duration time.Duration `json:"duration" binding:"-"`
+
+	// The speaking time limit that applied when the contribution started, zero if there was none
+	TimeLimit time.Duration `json:"time_limit" binding:"-"`
+
+	// Indicates, if the contribution took longer than its limit
+	Overrun bool `json:"overrun" binding:"-"`
+
+	// Indicates, if the contribution was stopped by the server because its time ran out
+	AutoStopped bool `json:"auto_stopped" binding:"-"`
+
+	// Indicates, if the contribution is currently paused
+	Paused bool `json:"paused" binding:"-"`
+
+	// The intervals in which the contribution was paused, they do not count as speaking time
+	Pauses []TalkingListPause `json:"pauses,omitempty" binding:"-"`
+
+	// Set once the warning about the ending speaking time has been published
+	warningPublished bool
+}
+
+// TalkingListPause represents an interval in which a contribution was paused
+type TalkingListPause struct {
+	// The time when the contribution was paused
+	StartTime time.Time `json:"start_time"`
+
+	// The time when the contribution was resumed, zero while it is still paused
+	EndTime time.Time `json:"end_time"`
+}
+
+// Calculate how long the contribution has been paused until now
+func (contribution *TalkingListContribution) pausedTime(now time.Time) time.Duration {
+	var paused time.Duration
+	for _, pause := range contribution.Pauses {
+		end := pause.EndTime
+		if end.IsZero() {
+			end = now
+		}
+		paused += end.Sub(pause.StartTime)
+	}
+	return paused
+}
+
+// Calculate the speaking time of the contribution until now, excluding pauses
+func (contribution *TalkingListContribution) speakingTime(now time.Time) time.Duration {
+	return now.Sub(contribution.StartTime) - contribution.pausedTime(now)
+}
+
+// Change the start and end of a finished contribution and update its duration.
+// Pauses are cut to the new times, those outside of them are dropped.
+func (contribution *TalkingListContribution) correctTimes(start time.Time, end time.Time) {
+	var pauses []TalkingListPause
+	for _, pause := range contribution.Pauses {
+		if pause.StartTime.Before(start) {
+			pause.StartTime = start
+		}
+		if pause.EndTime.After(end) {
+			pause.EndTime = end
+		}
+		if pause.EndTime.After(pause.StartTime) {
+			pauses = append(pauses, pause)
+		}
+	}
+
+	contribution.StartTime = start
+	contribution.EndTime = end
+	contribution.Pauses = pauses
+	contribution.Duration = contribution.speakingTime(end)
+	contribution.Overrun = contribution.TimeLimit > 0 && contribution.Duration > contribution.TimeLimit
 }
 
 // TalkingListAttendee represents a person that attends an event
@@ -87,11 +315,8 @@ type TalkingList struct {
 	// Name of the event
 	Name string `json:"name" binding:"required"`
 
-	// Visibility of this talking list
-	// 0 means this list is private, it can only be seen by administrators
-	// 1 means this list is unlisted, it can be seen by administrators and those who have a link
-	// 2 means this list is public, it can be seen by everybody
-	Visibility int `json:"visibility" binding:"-"`
+	// Visibility of this talking list, private if not given
+	Visibility TalkingListVisibility `json:"visibility" binding:"visibility"`
 
 	// Talking groups that are part of this event
 	Groups map[uuid.UUID]TalkingListGroup `json:"groups" binding:"-"`
@@ -105,11 +330,509 @@ type TalkingList struct {
 
 	// The list of previous contributions
 	PastContributions []TalkingListContribution `json:"past_contributions" binding:"-"`
+
+	// Limits of the speaking time of contributions
+	SpeakingTime TalkingListSpeakingTime `json:"speaking_time"`
+
+	// Determines who is called next from the applications of all groups
+	QueuePolicy TalkingListQueuePolicy `json:"queue_policy"`
+
+	// The agenda of the event
+	Agenda TalkingListAgenda `json:"agenda"`
+
+	// Motions put to the vote at this event
+	Motions map[uuid.UUID]TalkingListMotion `json:"motions" binding:"-"`
+
+	// Increased on every change of the list
+	Revision uint64 `json:"revision" binding:"-"`
+
+	// The last start or stop of a contribution, as long as it can be undone.
+	// It is not stored, so it is lost when the server restarts.
+	undo *contributionUndo
+}
+
+// TalkingListSpeakingTime represents the speaking time limits of a talking list
+type TalkingListSpeakingTime struct {
+	// Maximum speaking time of a contribution, zero means unlimited.
+	// Groups may override it with their own limit.
+	Limit time.Duration `json:"limit" binding:"min=0"`
+
+	// Speakers are warned when less than this time is remaining, zero disables the warning
+	WarningThreshold time.Duration `json:"warning_threshold" binding:"min=0"`
+
+	// Stop contributions on the server once their time has run out
+	AutoStop bool `json:"auto_stop"`
+}
+
+// TalkingListAgenda represents the agenda of an event
+type TalkingListAgenda struct {
+	// The agenda items, in order
+	Items []TalkingListAgendaItem `json:"items" binding:"dive"`
+
+	// The UUID of the agenda item that is currently discussed, null if there is none.
+	// Set by the server.
+	Current *uuid.UUID `json:"current" binding:"-"`
+}
+
+// TalkingListAgendaItem represents an item on the agenda of an event (TOP)
+type TalkingListAgendaItem struct {
+	// The UUID of the agenda item, set by the server
+	Uuid uuid.UUID `json:"uuid" binding:"-"`
+
+	// The title of the agenda item
+	Title string `json:"title" binding:"required"`
+}
+
+// States of a motion
+const (
+	// The motion has not been voted on yet
+	MotionStatusDraft = "draft"
+
+	// Voting on the motion is in progress
+	MotionStatusOpen = "open"
+
+	// Voting has ended and the result is recorded
+	MotionStatusClosed = "closed"
+)
+
+// Results of a vote
+const (
+	// More votes in favour than against
+	MotionResultAccepted = "accepted"
+
+	// At most as many votes in favour as against
+	MotionResultRejected = "rejected"
+)
+
+// TalkingListMotion represents a motion that is put to the vote
+type TalkingListMotion struct {
+	// The text of the motion
+	Text string `json:"text" binding:"required"`
+
+	// The person or group that moved the motion
+	Mover string `json:"mover" binding:"required"`
+
+	// The agenda item that was current when the motion was created, if any
+	AgendaItemUuid *uuid.UUID `json:"agenda_item_uuid,omitempty" binding:"-"`
+
+	// One of the MotionStatus constants, set by the server
+	Status string `json:"status" binding:"-"`
+
+	// The time when the motion was created
+	CreatedAt time.Time `json:"created_at" binding:"-"`
+
+	// The time when voting was opened
+	OpenedAt time.Time `json:"opened_at" binding:"-"`
+
+	// The time when voting was closed
+	ClosedAt time.Time `json:"closed_at" binding:"-"`
+
+	// The votes as entered by the moderator, once voting is closed
+	Votes *TalkingListVotes `json:"votes,omitempty" binding:"-"`
+
+	// One of the MotionResult constants, once voting is closed
+	Result string `json:"result,omitempty" binding:"-"`
+}
+
+// TalkingListVotes represents the votes cast on a motion
+type TalkingListVotes struct {
+	// The number of votes in favour
+	Yes int `json:"yes"`
+
+	// The number of votes against
+	No int `json:"no"`
+
+	// The number of abstentions
+	Abstain int `json:"abstain"`
+}
+
+// Get the result of a vote, a motion needs more votes in favour than against
+func (votes TalkingListVotes) result() string {
+	if votes.Yes > votes.No {
+		return MotionResultAccepted
+	}
+	return MotionResultRejected
+}
+
+// Policies determining who is called next
+const (
+	// The application submitted first is called first, regardless of its group
+	QueuePolicyFifo = "fifo"
+
+	// The groups take turns in a fixed order
+	QueuePolicyRoundRobin = "round_robin"
+
+	// Two groups take turns
+	QueuePolicyAlternating = "alternating"
+)
+
+// TalkingListQueuePolicy determines who is called next from the applications of all groups.
+// Within a group, applications are always called in the order of its queue.
+type TalkingListQueuePolicy struct {
+	// One of the QueuePolicy constants, FIFO if not given
+	Policy string `json:"policy" binding:"omitempty,oneof=fifo round_robin alternating"`
+
+	// The groups taking turns, in order. For round-robin all groups take turns if not given,
+	// ordered by name. Alternating requires exactly two groups.
+	Groups []uuid.UUID `json:"groups"`
+
+	// Let FLINTA* speakers and others alternate, on top of the policy
+	Quota bool `json:"quota"`
+
+	// Call people who have not spoken yet before repeat speakers, on top of the policy and quota
+	FirstTimeSpeakersFirst bool `json:"first_time_speakers_first"`
+
+	// The kinds of applications that jump the queue, most urgent first. They are called in
+	// the order of submission, regardless of policy and quota. Points of order before
+	// direct replies if not given, an empty list treats all applications as regular.
+	PriorityTypes []string `json:"priority_types" binding:"dive,oneof=direct_reply point_of_order"`
+
+	// Maximum number of direct replies to a single contribution, 1 if not given
+	DirectRepliesPerContribution *int `json:"direct_replies_per_contribution" binding:"omitempty,min=0"`
+
+	// Maximum number of points of order waiting at the same time, 1 if not given
+	PointsOfOrderAtOnce *int `json:"points_of_order_at_once" binding:"omitempty,min=0"`
+}
+
+// Get the kinds of applications that jump the queue, most urgent first
+func (policy *TalkingListQueuePolicy) priorityTypes() []string {
+	if policy.PriorityTypes == nil {
+		return []string{ApplicationTypePointOfOrder, ApplicationTypeDirectReply}
+	}
+	return policy.PriorityTypes
+}
+
+// Get how many applications of a kind may wait, or -1 if there is no limit
+func (policy *TalkingListQueuePolicy) applicationLimit(applicationType string) int {
+	limit := map[string]*int{
+		ApplicationTypeDirectReply:  policy.DirectRepliesPerContribution,
+		ApplicationTypePointOfOrder: policy.PointsOfOrderAtOnce,
+	}
+	value, limited := limit[applicationType]
+	if !limited {
+		return -1
+	}
+	if value == nil {
+		return 1
+	}
+	return *value
+}
+
+// Get the speaking time limit that applies to contributions of a group, zero if there is none
+func (list *TalkingList) speakingTimeLimit(groupUuid uuid.UUID) time.Duration {
+	if groupEntry, entryPresent := list.Groups[groupUuid]; entryPresent && groupEntry.SpeakingTimeLimit > 0 {
+		return groupEntry.SpeakingTimeLimit
+	}
+	return list.SpeakingTime.Limit
+}
+
+// Finish the current contribution, if there is one, and move it to the list of previous contributions
+func (list *TalkingList) finishCurrentContribution(now time.Time) {
+	if !list.CurrentContribution.InProgress {
+		return
+	}
+
+	prevContribution := list.CurrentContribution
+	if prevContribution.Paused {
+		prevContribution.Pauses = append([]TalkingListPause(nil), prevContribution.Pauses...)
+		prevContribution.Pauses[len(prevContribution.Pauses)-1].EndTime = now
+		prevContribution.Paused = false
+	}
+	prevContribution.correctTimes(prevContribution.StartTime, now)
+	prevContribution.InProgress = false
+	list.PastContributions = append(list.PastContributions, prevContribution)
+
+	list.CurrentContribution.InProgress = false
+}
+
+// Find a past contribution by its UUID, returns -1 if there is none
+func (list *TalkingList) pastContributionIndex(contributionUuid uuid.UUID) int {
+	for index, contribution := range list.PastContributions {
+		if contribution.Uuid == contributionUuid {
+			return index
+		}
+	}
+	return -1
+}
+
+// Give the contributions of databases of older versions a UUID
+func (list *TalkingList) assignContributionUuids() {
+	for index := range list.PastContributions {
+		if list.PastContributions[index].Uuid == uuid.Nil {
+			list.PastContributions[index].Uuid = uuid.New()
+		}
+	}
+	if list.CurrentContribution.InProgress && list.CurrentContribution.Uuid == uuid.Nil {
+		list.CurrentContribution.Uuid = uuid.New()
+	}
+}
+
+// Calculate how the speaking time of past contributions is distributed between the groups
+func (list *TalkingList) timeDistribution() TalkingListTimeDistribution {
+	distribution := TalkingListTimeDistribution{
+		TimeShare:           make(map[uuid.UUID]time.Duration),
+		NumberContributions: make(map[uuid.UUID]uint),
+	}
+
+	for uuid := range list.Groups {
+		distribution.TimeShare[uuid] = 0
+	}
+	distribution.QuotaTimeShare = map[string]time.Duration{QuotaCategoryFlinta: 0, QuotaCategoryOpen: 0}
+	distribution.QuotaNumberContributions = map[string]uint{QuotaCategoryFlinta: 0, QuotaCategoryOpen: 0}
+	distribution.TypeTimeShare = make(map[string]time.Duration)
+	distribution.TypeNumberContributions = make(map[string]uint)
+	for _, applicationType := range []string{ApplicationTypeRegular, ApplicationTypeDirectReply, ApplicationTypePointOfOrder} {
+		distribution.TypeTimeShare[applicationType] = 0
+		distribution.TypeNumberContributions[applicationType] = 0
+	}
+	distribution.Agenda = make([]TalkingListAgendaItemTimeDistribution, 0, len(list.Agenda.Items))
+	for _, item := range list.Agenda.Items {
+		itemDistribution := TalkingListAgendaItemTimeDistribution{
+			AgendaItemUuid:      item.Uuid,
+			TimeShare:           make(map[uuid.UUID]time.Duration),
+			NumberContributions: make(map[uuid.UUID]uint),
+		}
+		for uuid := range list.Groups {
+			itemDistribution.TimeShare[uuid] = 0
+		}
+		distribution.Agenda = append(distribution.Agenda, itemDistribution)
+	}
+
+	for _, contribution := range list.PastContributions {
+		distribution.TimeShare[contribution.GroupUuid] += contribution.Duration
+		distribution.NumberContributions[contribution.GroupUuid]++
+		distribution.TotalTime += contribution.Duration
+
+		category := contribution.Application.quotaCategory()
+		distribution.QuotaTimeShare[category] += contribution.Duration
+		distribution.QuotaNumberContributions[category]++
+
+		applicationType := contribution.Application.applicationType()
+		distribution.TypeTimeShare[applicationType] += contribution.Duration
+		distribution.TypeNumberContributions[applicationType]++
+
+		if index := list.agendaIndex(contribution.AgendaItemUuid); index >= 0 {
+			item := &distribution.Agenda[index]
+			item.TimeShare[contribution.GroupUuid] += contribution.Duration
+			item.NumberContributions[contribution.GroupUuid]++
+			item.TotalTime += contribution.Duration
+		}
+	}
+
+	return distribution
+}
+
+// Calculate the state of the timer of the current contribution, as seen by the server
+func (list *TalkingList) timer(now time.Time) TalkingListTimer {
+	// Only use the wall clock, so Elapsed matches the difference of the times sent to clients
+	now = now.Round(0)
+
+	timer := TalkingListTimer{
+		ServerTime: now,
+		Running:    list.CurrentContribution.InProgress,
+		Revision:   list.Revision,
+	}
+
+	if timer.Running {
+		timer.StartTime = list.CurrentContribution.StartTime
+		timer.Elapsed = list.CurrentContribution.speakingTime(now)
+		timer.Paused = list.CurrentContribution.Paused
+
+		if limit := list.CurrentContribution.TimeLimit; limit > 0 {
+			remaining := limit - timer.Elapsed
+			timer.Remaining = &remaining
+			timer.Warning = list.SpeakingTime.WarningThreshold > 0 && remaining <= list.SpeakingTime.WarningThreshold
+			timer.Overrun = remaining < 0
+		}
+	}
+
+	return timer
+}
+
+// TalkingListTimer represents the state of the timer of the current contribution.
+// Displays should show these values instead of calculating them with their own clocks.
+type TalkingListTimer struct {
+	// The time of the server when the timer was calculated
+	ServerTime time.Time `json:"server_time"`
+
+	// Indicates, if a contribution is in progress
+	Running bool `json:"running"`
+
+	// The time when the current contribution started
+	StartTime time.Time `json:"start_time"`
+
+	// The speaking time of the current contribution, excluding pauses
+	Elapsed time.Duration `json:"elapsed"`
+
+	// Indicates, if the current contribution is paused, so the timer does not advance
+	Paused bool `json:"paused"`
+
+	// The speaking time left, if the contribution has a limit.
+	// Negative once the contribution takes longer than its limit.
+	Remaining *time.Duration `json:"remaining,omitempty"`
+
+	// Indicates, if the speaker should be warned that the time is running out
+	Warning bool `json:"warning"`
+
+	// Indicates, if the contribution takes longer than its limit
+	Overrun bool `json:"overrun"`
+
+	// The revision of the list the timer belongs to
+	Revision uint64 `json:"revision"`
+}
+
+// TalkingListResponse is the representation of a single talking list sent to clients,
+// including values calculated by the server
+type TalkingListResponse struct {
+	TalkingList
+
+	// The timer of the current contribution
+	Timer TalkingListTimer `json:"timer"`
+}
+
+// Create the representation of a talking list sent to clients
+func (list *TalkingList) response(now time.Time) TalkingListResponse {
+	return TalkingListResponse{TalkingList: *list, Timer: list.timer(now)}
+}
+
+// TalkingListTimeDistribution represents how the speaking time of a talking list
+// is distributed between its groups
+type TalkingListTimeDistribution struct {
+	// The speaking time of each group
+	TimeShare map[uuid.UUID]time.Duration `json:"time_share"`
+
+	// The speaking time of all groups
+	TotalTime time.Duration `json:"total_time"`
+
+	// The number of contributions of each group
+	NumberContributions map[uuid.UUID]uint `json:"number_contributions"`
+
+	// The speaking time of each quota category
+	QuotaTimeShare map[string]time.Duration `json:"quota_time_share"`
+
+	// The number of contributions of each quota category
+	QuotaNumberContributions map[string]uint `json:"quota_number_contributions"`
+
+	// The speaking time of each kind of application
+	TypeTimeShare map[string]time.Duration `json:"type_time_share"`
+
+	// The number of contributions of each kind of application
+	TypeNumberContributions map[string]uint `json:"type_number_contributions"`
+
+	// The distribution within each agenda item, in the order of the agenda.
+	// Contributions that do not belong to an item only count towards the totals.
+	Agenda []TalkingListAgendaItemTimeDistribution `json:"agenda"`
+}
+
+// TalkingListAgendaItemTimeDistribution represents how the speaking time of the contributions
+// to an agenda item is distributed between the groups
+type TalkingListAgendaItemTimeDistribution struct {
+	// The UUID of the agenda item
+	AgendaItemUuid uuid.UUID `json:"agenda_item_uuid"`
+
+	// The speaking time of each group
+	TimeShare map[uuid.UUID]time.Duration `json:"time_share"`
+
+	// The speaking time of all groups
+	TotalTime time.Duration `json:"total_time"`
+
+	// The number of contributions of each group
+	NumberContributions map[uuid.UUID]uint `json:"number_contributions"`
 }
 
 // TalkingListVisibilityUpdate represents a request to change the
 // visibility of a talking list
 type TalkingListVisibilityUpdate struct {
 	// The new visibility of the list
-	NewVisibility int `json:"new_visibility"`
+	NewVisibility *TalkingListVisibility `json:"new_visibility" binding:"required,visibility"`
+}
+
+// TalkingListGroupSpeakingTimeUpdate represents a request to change the
+// speaking time limit of a group
+type TalkingListGroupSpeakingTimeUpdate struct {
+	// The new limit, zero to use the limit of the list
+	SpeakingTimeLimit *time.Duration `json:"speaking_time_limit" binding:"required,min=0"`
+}
+
+// TalkingListApplicationMove represents a request to move an application
+// to another position in the queue of its group
+type TalkingListApplicationMove struct {
+	// The new position, starting at 1. Positions after the end move the application to the end.
+	Position *int `json:"position" binding:"required,min=1"`
+}
+
+// TalkingListSpeaker identifies an application that is about to be called
+type TalkingListSpeaker struct {
+	// The UUID of the group the application belongs to
+	GroupUuid uuid.UUID `json:"group_uuid"`
+
+	// The UUID of the application
+	ApplicationUuid uuid.UUID `json:"application_uuid"`
+
+	// The application
+	Application TalkingListApplication `json:"application"`
+
+	// Indicates, if the application goes ahead of repeat speakers because the person has not spoken yet
+	Prioritised bool `json:"prioritised"`
+}
+
+// TalkingListNext is the result of calling the next speaker
+type TalkingListNext struct {
+	// The contribution that was started, nil if nobody applied
+	Current *TalkingListContribution `json:"current"`
+
+	// The application that will be called after it, nil if there is none
+	Following *TalkingListSpeaker `json:"following"`
+}
+
+// TalkingListContributionStart represents a request to start the contribution
+// of an application
+type TalkingListContributionStart struct {
+	// The UUID of the group the application belongs to
+	GroupUuid uuid.UUID `json:"group_uuid" binding:"required"`
+
+	// The UUID of the application
+	ApplicationUuid uuid.UUID `json:"application_uuid" binding:"required"`
+}
+
+// TalkingListContributionUpdate represents a correction of a past contribution.
+// Fields that are not given stay unchanged.
+type TalkingListContributionUpdate struct {
+	// The time when the contribution started
+	StartTime *time.Time `json:"start_time"`
+
+	// The time when the contribution ended
+	EndTime *time.Time `json:"end_time"`
+
+	// The UUID of the group the speaker belongs to
+	GroupUuid *uuid.UUID `json:"group_uuid"`
+
+	// The name of the speaker
+	Name *string `json:"name"`
+
+	// The attendee who spoke
+	AttendeeUuid *uuid.UUID `json:"attendee_uuid"`
+
+	// The agenda item the contribution belongs to
+	AgendaItemUuid *uuid.UUID `json:"agenda_item_uuid"`
+}
+
+// TalkingListMotionClose represents a request to close voting on a motion
+// with the votes counted by the moderator
+type TalkingListMotionClose struct {
+	// The number of votes in favour
+	Yes *int `json:"yes" binding:"required,min=0"`
+
+	// The number of votes against
+	No *int `json:"no" binding:"required,min=0"`
+
+	// The number of abstentions
+	Abstain *int `json:"abstain" binding:"required,min=0"`
+}
+
+// TalkingListAgendaItemMove represents a request to move an agenda item
+// to another position on the agenda
+type TalkingListAgendaItemMove struct {
+	// The new position, starting at 1. Positions after the end move the item to the end.
+	Position *int `json:"position" binding:"required,min=1"`
 }
@@ -0,0 +1,369 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// Types of messages sent to moderators
+const (
+	moderatorMessageEvent = "event"
+	moderatorMessageAck   = "ack"
+	moderatorMessageError = "error"
+)
+
+const (
+	// Interval of pings sent to detect broken connections
+	moderatorPingInterval = 30 * time.Second
+
+	// Time without any message from the moderator, after which the connection is considered broken
+	moderatorReadTimeout = 2 * moderatorPingInterval
+
+	// Time after which writing a message is given up
+	moderatorWriteTimeout = 10 * time.Second
+
+	// Maximum size of a command in bytes
+	moderatorMaxCommandSize = 64 * 1024
+)
+
+// ModeratorCommand is a command sent by a moderator over the WebSocket connection
+type ModeratorCommand struct {
+	// Chosen by the moderator, the reply to this command carries the same ID
+	RequestId string `json:"request_id" binding:"required"`
+
+	// The name of the command, e.g. "start"
+	Command string `json:"command" binding:"required"`
+
+	// The group the command refers to, if any
+	GroupUuid uuid.UUID `json:"group_uuid"`
+
+	// The application the command refers to, if any
+	ApplicationUuid uuid.UUID `json:"application_uuid"`
+
+	// The new position of the application for "reorder", starting at 1
+	Position int `json:"position"`
+}
+
+// ModeratorMessage is a message sent to a moderator over the WebSocket connection.
+// It is either an event of the list, or the reply to a command.
+type ModeratorMessage struct {
+	// Type of the message, one of the moderatorMessage constants
+	Type string `json:"type"`
+
+	// For replies, the ID of the command
+	RequestId string `json:"request_id,omitempty"`
+
+	// For events, the ID and the type of the event, see ListEvent
+	EventId *uint64 `json:"id,omitempty"`
+	Event   string  `json:"event,omitempty"`
+
+	// For events and acknowledgements, data depending on the event or command
+	Data any `json:"data,omitempty"`
+
+	// For errors, the reason why the command failed
+	Error *APIError `json:"error,omitempty"`
+}
+
+// A command handler executes a command on a list and returns the data of the acknowledgement.
+// listsMutex is held while it is called.
+type moderatorCommandHandler func(context *gin.Context, listUuid uuid.UUID, command ModeratorCommand) (any, error)
+
+// All commands a moderator may send, by name
+var moderatorCommands = map[string]moderatorCommandHandler{
+	"start": func(context *gin.Context, listUuid uuid.UUID, command ModeratorCommand) (any, error) {
+		if err := requireCommandApplication(command); err != nil {
+			return nil, err
+		}
+		return startApplicationContribution(context, listUuid, command.GroupUuid, command.ApplicationUuid)
+	},
+	"stop": func(context *gin.Context, listUuid uuid.UUID, command ModeratorCommand) (any, error) {
+		return nil, stopCurrentContribution(context, listUuid)
+	},
+	"delete": func(context *gin.Context, listUuid uuid.UUID, command ModeratorCommand) (any, error) {
+		if err := requireCommandApplication(command); err != nil {
+			return nil, err
+		}
+		return nil, withdrawApplication(context, listUuid, command.GroupUuid, command.ApplicationUuid)
+	},
+	"pause": func(context *gin.Context, listUuid uuid.UUID, command ModeratorCommand) (any, error) {
+		return pauseCurrentContribution(context, listUuid)
+	},
+	"resume": func(context *gin.Context, listUuid uuid.UUID, command ModeratorCommand) (any, error) {
+		return resumeCurrentContribution(context, listUuid)
+	},
+	"next": func(context *gin.Context, listUuid uuid.UUID, command ModeratorCommand) (any, error) {
+		return startNextContribution(context, listUuid)
+	},
+	"next_agenda_item": func(context *gin.Context, listUuid uuid.UUID, command ModeratorCommand) (any, error) {
+		return advanceAgenda(context, listUuid)
+	},
+	"undo": func(context *gin.Context, listUuid uuid.UUID, command ModeratorCommand) (any, error) {
+		return undoContributionAction(context, listUuid)
+	},
+	"reorder": func(context *gin.Context, listUuid uuid.UUID, command ModeratorCommand) (any, error) {
+		if err := requireCommandApplication(command); err != nil {
+			return nil, err
+		}
+		if command.Position < 1 {
+			return nil, &APIError{Code: errorCodeInvalidBody, Message: "The command is invalid",
+				Fields: []APIFieldError{{Field: "position", Message: "must be at least 1"}}}
+		}
+		return moveApplication(context, listUuid, command.GroupUuid, command.ApplicationUuid, command.Position)
+	},
+}
+
+// The origin of connections is already checked by the CORS middleware
+var moderatorUpgrader = websocket.Upgrader{
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// Check that a command refers to an application
+func requireCommandApplication(command ModeratorCommand) error {
+	var fields []APIFieldError
+	if command.GroupUuid == uuid.Nil {
+		fields = append(fields, APIFieldError{Field: "group_uuid", Message: "is required"})
+	}
+	if command.ApplicationUuid == uuid.Nil {
+		fields = append(fields, APIFieldError{Field: "application_uuid", Message: "is required"})
+	}
+	if len(fields) > 0 {
+		return &APIError{Code: errorCodeInvalidBody, Message: "The command is invalid", Fields: fields}
+	}
+	return nil
+}
+
+// Middleware that accepts the JSON Web Token in the query parameter "token",
+// as browsers can not set the Authorization header on WebSocket connections
+func tokenFromQuery() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if token := context.Query("token"); token != "" && context.GetHeader("Authorization") == "" {
+			context.Request.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		context.Next()
+	}
+}
+
+// Get the time the JSON Web Token of an authenticated request expires.
+// A token without expiry is treated as expired.
+func tokenExpiry(context *gin.Context) time.Time {
+	expiry, ok := jwt.ExtractClaims(context)["exp"].(float64)
+	if !ok {
+		return time.Time{}
+	}
+	return time.Unix(int64(expiry), 0)
+}
+
+// Register the endpoints of the moderator channel.
+// Like the event streams, they must not be registered with lockDatabase.
+func setupModeratorRoutes(protected *gin.RouterGroup) {
+	protected.GET("/list/:uuid/moderate", moderateList)
+}
+
+// Run a command of a moderator and create the reply
+func runModeratorCommand(context *gin.Context, listUuid uuid.UUID, command ModeratorCommand, err error) ModeratorMessage {
+	reply := ModeratorMessage{Type: moderatorMessageError, RequestId: command.RequestId}
+
+	if err == nil {
+		err = binding.Validator.ValidateStruct(command)
+	}
+	if err != nil {
+		apiError := bindErrorToAPIError(err)
+		reply.Error = &apiError
+		return reply
+	}
+
+	handler, known := moderatorCommands[command.Command]
+	if !known {
+		reply.Error = &APIError{Code: errorCodeUnknownCommand, Message: "The command " + command.Command + " does not exist"}
+		return reply
+	}
+
+	listsMutex.Lock()
+	var data json.RawMessage
+	if _, present := lists[listUuid]; !present {
+		err = errListNotFound
+	} else {
+		var result any
+		result, err = handler(context, listUuid, command)
+		if err == nil && result != nil {
+			data, err = json.Marshal(result)
+		}
+	}
+	listsMutex.Unlock()
+
+	switch actionErr := err.(type) {
+	case nil:
+		reply.Type = moderatorMessageAck
+		if data != nil {
+			reply.Data = data
+		}
+	case *APIError:
+		reply.Error = actionErr
+	case *listActionError:
+		reply.Error = &APIError{Code: actionErr.Code, Message: actionErr.Message}
+	default:
+		reply.Error = &APIError{Code: errorCodeInternal, Message: err.Error()}
+	}
+
+	return reply
+}
+
+// A command read from the connection, or the reason why it could not be decoded
+type moderatorRequest struct {
+	command ModeratorCommand
+	err     error
+}
+
+// Read commands from a connection until it is closed
+func readModeratorCommands(connection *websocket.Conn, requests chan<- moderatorRequest, done <-chan struct{}) {
+	defer close(requests)
+
+	connection.SetReadLimit(moderatorMaxCommandSize)
+	connection.SetReadDeadline(time.Now().Add(moderatorReadTimeout))
+	connection.SetPongHandler(func(string) error {
+		return connection.SetReadDeadline(time.Now().Add(moderatorReadTimeout))
+	})
+
+	for {
+		_, message, err := connection.ReadMessage()
+		if err != nil {
+			return
+		}
+		connection.SetReadDeadline(time.Now().Add(moderatorReadTimeout))
+
+		var request moderatorRequest
+		request.err = json.Unmarshal(message, &request.command)
+
+		select {
+		case requests <- request:
+		case <-done:
+			return
+		}
+	}
+}
+
+// Open the moderator channel of a talking list.
+// Moderators receive a snapshot and all events of the list, and may send commands, which
+// are acknowledged or rejected with a reply carrying the request ID of the command.
+func moderateList(context *gin.Context) {
+	listUuid, ok := parseUuidParam(context, "uuid")
+	if !ok {
+		return
+	}
+
+	// The list must not change between taking the snapshot and subscribing
+	listsMutex.Lock()
+	listEntry, present := lists[listUuid]
+	if !present {
+		listsMutex.Unlock()
+		abortWithError(context, http.StatusNotFound, errorCodeNotFound, "The talking list does not exist")
+		return
+	}
+	events, _, currentId, _ := subscribeListEvents(listUuid, nil)
+	snapshot, err := json.Marshal(ModeratorMessage{Type: moderatorMessageEvent, EventId: &currentId, Event: eventSnapshot, Data: listEntry.response(time.Now())})
+	listsMutex.Unlock()
+	defer unsubscribeListEvents(listUuid, events)
+	if err != nil {
+		abortWithError(context, http.StatusInternalServerError, errorCodeInternal, err.Error())
+		return
+	}
+
+	connection, err := moderatorUpgrader.Upgrade(context.Writer, context.Request, nil)
+	if err != nil {
+		// The upgrader already responded with an error
+		return
+	}
+	defer connection.Close()
+
+	write := func(message ModeratorMessage) bool {
+		connection.SetWriteDeadline(time.Now().Add(moderatorWriteTimeout))
+		return connection.WriteJSON(message) == nil
+	}
+	closeWith := func(code int, text string) {
+		message := websocket.FormatCloseMessage(code, text)
+		connection.WriteControl(websocket.CloseMessage, message, time.Now().Add(moderatorWriteTimeout))
+	}
+
+	connection.SetWriteDeadline(time.Now().Add(moderatorWriteTimeout))
+	if connection.WriteMessage(websocket.TextMessage, snapshot) != nil {
+		return
+	}
+
+	requests := make(chan moderatorRequest)
+	done := make(chan struct{})
+	defer close(done)
+	go readModeratorCommands(connection, requests, done)
+
+	ping := time.NewTicker(moderatorPingInterval)
+	defer ping.Stop()
+
+	// The token was only checked when connecting, the moderator has to reconnect with a new one
+	expiry := tokenExpiry(context)
+	expired := time.NewTimer(time.Until(expiry))
+	defer expired.Stop()
+
+	for {
+		select {
+		case request, open := <-requests:
+			if !open {
+				return
+			}
+			if !time.Now().Before(expiry) {
+				closeWith(websocket.ClosePolicyViolation, "The token has expired")
+				return
+			}
+			if !write(runModeratorCommand(context, listUuid, request.command, request.err)) {
+				return
+			}
+		case event, open := <-events:
+			if !open {
+				// The server shuts down or the moderator did not keep up with the events
+				closeWith(websocket.CloseGoingAway, "Reconnect to receive further updates")
+				return
+			}
+			if !write(ModeratorMessage{Type: moderatorMessageEvent, EventId: &event.Id, Event: event.Type, Data: event.Data}) {
+				return
+			}
+			if event.Type == eventListDeleted {
+				closeWith(websocket.CloseNormalClosure, "The talking list was deleted")
+				return
+			}
+		case <-ping.C:
+			if connection.WriteControl(websocket.PingMessage, nil, time.Now().Add(moderatorWriteTimeout)) != nil {
+				return
+			}
+		case <-expired.C:
+			closeWith(websocket.ClosePolicyViolation, "The token has expired")
+			return
+		}
+	}
+}
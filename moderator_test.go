@@ -0,0 +1,210 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// Connect to the moderator channel of a list
+func dialModerator(t *testing.T, server *httptest.Server, listUuid uuid.UUID, token string) *websocket.Conn {
+	t.Helper()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/protected/list/" + listUuid.String() + "/moderate?token=" + token
+	connection, response, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		status := 0
+		if response != nil {
+			status = response.StatusCode
+		}
+		t.Fatalf("Connecting to the moderator channel failed with status %d: %v", status, err)
+	}
+	t.Cleanup(func() { connection.Close() })
+
+	return connection
+}
+
+// Read the next message from the moderator channel
+func readModeratorMessage(t *testing.T, connection *websocket.Conn) ModeratorMessage {
+	t.Helper()
+
+	connection.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var message ModeratorMessage
+	if err := connection.ReadJSON(&message); err != nil {
+		t.Fatalf("Reading from the moderator channel failed: %v", err)
+	}
+	return message
+}
+
+// Read messages until the reply to a command arrives, returning it and the events received before
+func readModeratorReply(t *testing.T, connection *websocket.Conn, requestId string) (ModeratorMessage, []string) {
+	t.Helper()
+
+	var events []string
+	for {
+		message := readModeratorMessage(t, connection)
+		if message.Type == moderatorMessageEvent {
+			events = append(events, message.Event)
+		} else if message.RequestId == requestId {
+			return message, events
+		}
+	}
+}
+
+func TestModeratorChannelRequiresAuthentication(t *testing.T) {
+	router := setupTestRouter(t)
+	server := newTestServer(t, router)
+	listUuid, _, _ := addTestList(VisibilityPublic)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/protected/list/" + listUuid.String() + "/moderate"
+	_, response, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil || response == nil || response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Connecting without a token did not fail with status %d: %v", http.StatusUnauthorized, err)
+	}
+}
+
+func TestModeratorCommands(t *testing.T) {
+	router := setupTestRouter(t)
+	server := newTestServer(t, router)
+
+	token := login(t, router)
+	listUuid, groupUuid, applicationUuid := addTestList(VisibilityPrivate)
+	moderator := dialModerator(t, server, listUuid, token)
+	coModerator := dialModerator(t, server, listUuid, token)
+
+	for _, connection := range []*websocket.Conn{moderator, coModerator} {
+		if message := readModeratorMessage(t, connection); message.Event != eventSnapshot {
+			t.Fatalf("The first message is %+v, expected a snapshot", message)
+		}
+	}
+
+	moderator.WriteJSON(ModeratorCommand{RequestId: "1", Command: "start", GroupUuid: groupUuid, ApplicationUuid: applicationUuid})
+	reply, _ := readModeratorReply(t, moderator, "1")
+	if reply.Type != moderatorMessageAck || !lists[listUuid].CurrentContribution.InProgress {
+		t.Errorf("Starting a contribution was replied to with %+v", reply)
+	}
+
+	// The co-moderator is informed about the change
+	for _, event := range []string{eventApplicationDeleted, eventContributionStarted} {
+		if message := readModeratorMessage(t, coModerator); message.Event != event {
+			t.Errorf("The co-moderator received %+v, expected %s", message, event)
+		}
+	}
+
+	moderator.WriteJSON(ModeratorCommand{RequestId: "2", Command: "delete", GroupUuid: groupUuid, ApplicationUuid: applicationUuid})
+	reply, _ = readModeratorReply(t, moderator, "2")
+	if reply.Type != moderatorMessageError || reply.Error == nil || reply.Error.Code != errorCodeNotFound {
+		t.Errorf("Deleting a missing application was replied to with %+v", reply)
+	}
+
+	moderator.WriteJSON(ModeratorCommand{RequestId: "3", Command: "stop"})
+	reply, events := readModeratorReply(t, moderator, "3")
+	if reply.Type != moderatorMessageAck || lists[listUuid].CurrentContribution.InProgress {
+		t.Errorf("Stopping the contribution was replied to with %+v", reply)
+	}
+	if len(events) == 0 {
+		events = append(events, readModeratorMessage(t, moderator).Event)
+	}
+	if events[0] != eventContributionStopped {
+		t.Errorf("The moderator received %v, expected %s", events, eventContributionStopped)
+	}
+}
+
+func TestModeratorCommandErrors(t *testing.T) {
+	router := setupTestRouter(t)
+	server := newTestServer(t, router)
+
+	token := login(t, router)
+	listUuid, groupUuid, applicationUuid := addTestList(VisibilityPublic)
+	moderator := dialModerator(t, server, listUuid, token)
+	readModeratorMessage(t, moderator)
+	application := `"group_uuid": "` + groupUuid.String() + `", "application_uuid": "` + applicationUuid.String() + `"`
+
+	tests := []struct {
+		command string
+		code    string
+	}{
+		{`{"request_id": "a", "command": "dance"}`, errorCodeUnknownCommand},
+		{`{"request_id": "b", "command": "start"}`, errorCodeInvalidBody},
+		{`{"request_id": "c"}`, errorCodeInvalidBody},
+		{`{"request_id": "d", "command": "start", "group_uuid": "x"}`, errorCodeInvalidBody},
+		{`{"request_id": "e", "command": "reorder", ` + application + `}`, errorCodeInvalidBody},
+	}
+	for _, test := range tests {
+		moderator.WriteMessage(websocket.TextMessage, []byte(test.command))
+		reply := readModeratorMessage(t, moderator)
+		if reply.Type != moderatorMessageError || reply.Error == nil || reply.Error.Code != test.code {
+			t.Errorf("%s was replied to with %+v, expected error %s", test.command, reply, test.code)
+		}
+	}
+}
+
+func TestModeratorSnapshotWhileListChanges(t *testing.T) {
+	router := setupTestRouter(t)
+	server := newTestServer(t, router)
+
+	token := login(t, router)
+	listUuid, groupUuid, _ := addTestList(VisibilityPublic)
+	applicationsPath := "/v1/public/list/" + listUuid.String() + "/group/" + groupUuid.String() + "/application"
+
+	changesDone := make(chan struct{})
+	go func() {
+		defer close(changesDone)
+		for i := 0; i < 100; i++ {
+			doRequest(router, http.MethodPost, applicationsPath, `{"name": "Alice"}`, "")
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		connection := dialModerator(t, server, listUuid, token)
+		if message := readModeratorMessage(t, connection); message.Event != eventSnapshot {
+			t.Fatalf("Received %s as first message, expected the snapshot", message.Event)
+		}
+		connection.Close()
+	}
+	<-changesDone
+}
+
+func TestModeratorChannelClosesWhenTokenExpires(t *testing.T) {
+	router := setupTestRouter(t, func(c *Config) {
+		c.Authentication.TimeoutSeconds = 2
+	})
+	server := newTestServer(t, router)
+
+	token := login(t, router)
+	listUuid, _, _ := addTestList(VisibilityPublic)
+	connection := dialModerator(t, server, listUuid, token)
+	readModeratorMessage(t, connection)
+
+	connection.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := connection.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Errorf("Expected the connection to be closed when the token expires, got %v", err)
+	}
+}
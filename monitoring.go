@@ -0,0 +1,196 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// The registry containing all metrics exported by this software
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	// Number of handled HTTP requests, by route and status
+	metricRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "listomatic_http_requests_total",
+		Help: "Number of handled HTTP requests.",
+	}, []string{"method", "route", "status"})
+
+	// Latency of HTTP requests, by route
+	metricRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "listomatic_http_request_duration_seconds",
+		Help:    "Latency of HTTP requests.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// Duration of writing the database to disk
+	metricPersistenceDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "listomatic_persistence_write_duration_seconds",
+		Help:    "Duration of writing the database to disk.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	// Number of failed attempts to write the database to disk
+	metricPersistenceFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "listomatic_persistence_write_failures_total",
+		Help: "Number of failed attempts to write the database to disk.",
+	})
+
+	// Number of failed login attempts
+	metricLoginFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "listomatic_login_failures_total",
+		Help: "Number of failed login attempts.",
+	})
+)
+
+// databaseCollector exports the current state of the talking lists
+// each time the metrics are scraped.
+type databaseCollector struct {
+	lists               *prometheus.Desc
+	pendingApplications *prometheus.Desc
+	activeContributions *prometheus.Desc
+}
+
+func newDatabaseCollector() *databaseCollector {
+	return &databaseCollector{
+		lists: prometheus.NewDesc(
+			"listomatic_talking_lists", "Number of talking lists.", nil, nil),
+		pendingApplications: prometheus.NewDesc(
+			"listomatic_pending_applications", "Number of applications waiting to speak.", nil, nil),
+		activeContributions: prometheus.NewDesc(
+			"listomatic_active_contributions", "Number of contributions in progress.", nil, nil),
+	}
+}
+
+func (collector *databaseCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- collector.lists
+	descs <- collector.pendingApplications
+	descs <- collector.activeContributions
+}
+
+func (collector *databaseCollector) Collect(metrics chan<- prometheus.Metric) {
+	listsMutex.Lock()
+	numLists := len(lists)
+	numApplications := 0
+	numContributions := 0
+	for _, list := range lists {
+		for _, group := range list.Groups {
+			numApplications += len(group.Applications)
+		}
+		if list.CurrentContribution.InProgress {
+			numContributions++
+		}
+	}
+	listsMutex.Unlock()
+
+	metrics <- prometheus.MustNewConstMetric(collector.lists, prometheus.GaugeValue, float64(numLists))
+	metrics <- prometheus.MustNewConstMetric(collector.pendingApplications, prometheus.GaugeValue, float64(numApplications))
+	metrics <- prometheus.MustNewConstMetric(collector.activeContributions, prometheus.GaugeValue, float64(numContributions))
+}
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		metricRequests,
+		metricRequestDuration,
+		metricPersistenceDuration,
+		metricPersistenceFailures,
+		metricLoginFailures,
+		newDatabaseCollector(),
+	)
+}
+
+// databaseStatus is the state of the database reported by the readiness endpoint
+var databaseStatus struct {
+	// Protects all fields below
+	mutex sync.Mutex
+
+	// Flag, if the database was loaded from disk (or did not exist yet)
+	loaded bool
+
+	// The error of the last attempt to write the database to disk, if it failed
+	lastWriteErr error
+}
+
+// Middleware that records count and latency of each request
+func metricsMiddleware() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		start := time.Now()
+		context.Next()
+
+		// Use the route pattern, so requests for different lists share a time series
+		route := context.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		method := context.Request.Method
+		metricRequests.WithLabelValues(method, route, strconv.Itoa(context.Writer.Status())).Inc()
+		metricRequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Register the endpoints used by load balancers and monitoring systems
+func setupMonitoringRoutes(router *gin.RouterGroup) {
+	// The process is alive and serves requests
+	router.GET("/healthz", func(context *gin.Context) {
+		context.JSON(http.StatusOK, gin.H{
+			"status": "ok",
+		})
+	})
+
+	// The process is able to serve the talking lists:
+	// the database was loaded and the last write to disk succeeded
+	router.GET("/readyz", func(context *gin.Context) {
+		databaseStatus.mutex.Lock()
+		loaded := databaseStatus.loaded
+		lastWriteErr := databaseStatus.lastWriteErr
+		databaseStatus.mutex.Unlock()
+
+		status := http.StatusOK
+		response := gin.H{
+			"database_loaded":  loaded,
+			"last_write_error": nil,
+		}
+		if lastWriteErr != nil {
+			response["last_write_error"] = lastWriteErr.Error()
+			status = http.StatusServiceUnavailable
+		}
+		if !loaded {
+			status = http.StatusServiceUnavailable
+		}
+
+		context.JSON(status, response)
+	})
+
+	// Metrics in Prometheus text format
+	router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})))
+}
@@ -0,0 +1,129 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHealthAndReadiness(t *testing.T) {
+	router := setupTestRouter(t)
+
+	response := doRequest(router, http.MethodGet, "/healthz", "", "")
+	if response.Code != http.StatusOK {
+		t.Errorf("GET /healthz returned status %d, expected %d", response.Code, http.StatusOK)
+	}
+
+	tests := []struct {
+		loaded       bool
+		lastWriteErr error
+		status       int
+	}{
+		{true, nil, http.StatusOK},
+		{false, nil, http.StatusServiceUnavailable},
+		{true, errors.New("disk full"), http.StatusServiceUnavailable},
+	}
+
+	for _, test := range tests {
+		databaseStatus.mutex.Lock()
+		databaseStatus.loaded = test.loaded
+		databaseStatus.lastWriteErr = test.lastWriteErr
+		databaseStatus.mutex.Unlock()
+
+		response := doRequest(router, http.MethodGet, "/readyz", "", "")
+		var body struct {
+			DatabaseLoaded bool    `json:"database_loaded"`
+			LastWriteError *string `json:"last_write_error"`
+		}
+		if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Decoding response failed: %v", err)
+		}
+
+		if response.Code != test.status || body.DatabaseLoaded != test.loaded || (body.LastWriteError != nil) != (test.lastWriteErr != nil) {
+			t.Errorf("GET /readyz with loaded %v and write error %v returned status %d and %s",
+				test.loaded, test.lastWriteErr, response.Code, response.Body.String())
+		}
+	}
+
+	databaseStatus.mutex.Lock()
+	databaseStatus.loaded = true
+	databaseStatus.lastWriteErr = nil
+	databaseStatus.mutex.Unlock()
+}
+
+func TestMetricsCountRequests(t *testing.T) {
+	router := setupTestRouter(t)
+	router.GET("/panic", func(*gin.Context) {
+		panic("test")
+	})
+
+	doRequest(router, http.MethodGet, "/v1/public/list", "", "")
+	if response := doRequest(router, http.MethodGet, "/panic", "", ""); response.Code != http.StatusInternalServerError {
+		t.Fatalf("GET /panic returned status %d, expected %d", response.Code, http.StatusInternalServerError)
+	}
+
+	response := doRequest(router, http.MethodGet, "/metrics", "", "")
+	if response.Code != http.StatusOK {
+		t.Fatalf("GET /metrics returned status %d, expected %d", response.Code, http.StatusOK)
+	}
+
+	for _, series := range []string{
+		`listomatic_http_requests_total{method="GET",route="/v1/public/list",status="200"}`,
+		`listomatic_http_requests_total{method="GET",route="/panic",status="500"}`,
+		`listomatic_http_request_duration_seconds_count{method="GET",route="/v1/public/list"}`,
+		`listomatic_talking_lists `,
+	} {
+		if !strings.Contains(response.Body.String(), series) {
+			t.Errorf("The metrics do not contain %s", series)
+		}
+	}
+}
+
+func TestUnreadableDatabaseIsNotOverwritten(t *testing.T) {
+	databasePath := filepath.Join(t.TempDir(), "talking_lists.json")
+	broken := []byte(`{"not": "a list`)
+	if err := os.WriteFile(databasePath, broken, 0600); err != nil {
+		t.Fatalf("Writing database failed: %v", err)
+	}
+	router := setupTestRouter(t, func(c *Config) {
+		c.Database.TalkingListsPath = databasePath
+	})
+	token := login(t, router)
+
+	// Changes are still possible, but only kept in memory
+	createResource(t, router, "/v1/protected/list", `{"name": "Test"}`, token)
+
+	if content, err := os.ReadFile(databasePath); err != nil || string(content) != string(broken) {
+		t.Errorf("The unreadable database was overwritten with %s", content)
+	}
+	if response := doRequest(router, http.MethodGet, "/readyz", "", ""); response.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /readyz returned status %d, expected %d", response.Code, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,112 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMotionVoting(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+	listUuid, _, _ := addTestList(VisibilityPublic)
+	listPath := "/v1/protected/list/" + listUuid.String()
+	items := addTestAgenda(t, router, token, listUuid, "Haushalt")
+	doRequest(router, http.MethodPost, listPath+"/next_agenda_item", "", token)
+
+	if response := doRequest(router, http.MethodPost, listPath+"/motion", `{"text": "Der Haushalt wird beschlossen."}`, token); response.Code != http.StatusBadRequest {
+		t.Errorf("Creating a motion without mover returned status %d, expected %d", response.Code, http.StatusBadRequest)
+	}
+
+	response := doRequest(router, http.MethodPost, listPath+"/motion", `{"text": "Der Haushalt wird beschlossen.", "mover": "Finanzreferat", "status": "closed"}`, token)
+	var created struct {
+		TalkingListMotion
+		Uuid string `json:"uuid"`
+	}
+	if err := json.Unmarshal(response.Body.Bytes(), &created); err != nil || response.Code != http.StatusCreated {
+		t.Fatalf("Creating the motion returned status %d: %s", response.Code, response.Body.String())
+	}
+	if created.Status != MotionStatusDraft || !sameAgendaItem(created.AgendaItemUuid, &items[0]) {
+		t.Errorf("The motion was created as %+v", created.TalkingListMotion)
+	}
+	motionPath := listPath + "/motion/" + created.Uuid
+
+	votes := `{"yes": 12, "no": 3, "abstain": 2}`
+	if response := doRequest(router, http.MethodPost, motionPath+"/close", votes, token); response.Code != http.StatusConflict {
+		t.Errorf("Closing a motion that is not open returned status %d, expected %d", response.Code, http.StatusConflict)
+	}
+	doRequest(router, http.MethodPost, motionPath+"/open", "", token)
+	if response := doRequest(router, http.MethodPost, motionPath+"/open", "", token); response.Code != http.StatusConflict {
+		t.Errorf("Opening a motion twice returned status %d, expected %d", response.Code, http.StatusConflict)
+	}
+	if response := doRequest(router, http.MethodPost, motionPath+"/close", `{"yes": 12, "no": -1}`, token); response.Code != http.StatusBadRequest {
+		t.Errorf("Closing a motion with invalid votes returned status %d, expected %d", response.Code, http.StatusBadRequest)
+	}
+	if response := doRequest(router, http.MethodPost, motionPath+"/close", votes, token); response.Code != http.StatusOK {
+		t.Fatalf("Closing the motion returned status %d: %s", response.Code, response.Body.String())
+	}
+
+	var motion TalkingListMotion
+	response = doRequest(router, http.MethodGet, "/v1/public/list/"+listUuid.String()+"/motion/"+created.Uuid, "", "")
+	if err := json.Unmarshal(response.Body.Bytes(), &motion); err != nil {
+		t.Fatalf("Decoding the motion failed: %v", err)
+	}
+	if motion.Status != MotionStatusClosed || motion.Votes == nil || *motion.Votes != (TalkingListVotes{Yes: 12, No: 3, Abstain: 2}) ||
+		motion.Result != MotionResultAccepted || motion.ClosedAt.Before(motion.OpenedAt) {
+		t.Errorf("The closed motion is %+v", motion)
+	}
+
+	report := doRequest(router, http.MethodGet, listPath+"/mdreport", "", token).Body.String()
+	if !strings.Contains(report, "| 1 | Der Haushalt wird beschlossen. | Finanzreferat | TOP 1: Haushalt | abgestimmt | 12 | 3 | 2 | **angenommen** |") {
+		t.Errorf("The report does not contain the motion:\n%s", report)
+	}
+
+	if response := doRequest(router, http.MethodDelete, listPath+"/agenda/"+items[0].String(), "", token); response.Code != http.StatusConflict {
+		t.Errorf("Deleting an agenda item with a motion returned status %d, expected %d", response.Code, http.StatusConflict)
+	}
+
+	doRequest(router, http.MethodDelete, motionPath, "", token)
+	result := queryAudit(t, router, token, "?list="+listUuid.String())
+	if result.Total != 2 || result.Entries[0].Action != auditActionDeleteMotion || result.Entries[1].Action != auditActionCloseMotion ||
+		result.Entries[1].Details["result"] != MotionResultAccepted {
+		t.Errorf("The votes were recorded as %+v", result.Entries)
+	}
+}
+
+func TestMotionResult(t *testing.T) {
+	tests := []struct {
+		votes  TalkingListVotes
+		result string
+	}{
+		{TalkingListVotes{Yes: 5, No: 4, Abstain: 10}, MotionResultAccepted},
+		{TalkingListVotes{Yes: 4, No: 4}, MotionResultRejected},
+		{TalkingListVotes{Abstain: 3}, MotionResultRejected},
+	}
+	for _, test := range tests {
+		if result := test.votes.result(); result != test.result {
+			t.Errorf("%+v resulted in %s, expected %s", test.votes, result, test.result)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Maximum time in seconds a request may wait for a change of a list
+const pollingMaxWaitSeconds = 60
+
+// Register the endpoints that support conditional requests and long polling.
+// They must not be registered with lockDatabase, as the database would be locked while waiting.
+func setupPollingRoutes(public *gin.RouterGroup, protected *gin.RouterGroup) {
+	public.GET("/list/:uuid", func(context *gin.Context) { pollList(context, true) })
+	protected.GET("/list/:uuid", func(context *gin.Context) { pollList(context, false) })
+}
+
+// The entity tag of a revision of a list.
+// It is weak, as the representation may contain values that change over time.
+func listETag(revision uint64) string {
+	return `W/"` + strconv.FormatUint(revision, 10) + `"`
+}
+
+// Check whether an entity tag is listed in an If-None-Match header, using the weak comparison
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Retrieve a talking list.
+// If the client already has the current revision (If-None-Match), 304 Not Modified is returned.
+// With the query parameter "wait", the response is delayed for up to that many seconds until the list changes.
+// Public requests only reach unlisted and public lists, like with publicListAccess.
+func pollList(context *gin.Context, public bool) {
+	listUuid, ok := parseUuidParam(context, "uuid")
+	if !ok {
+		return
+	}
+
+	var wait time.Duration
+	if value := context.Query("wait"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 || seconds > pollingMaxWaitSeconds {
+			abortWithError(context, http.StatusBadRequest, errorCodeInvalidParameter, "A parameter is invalid",
+				APIFieldError{Field: "wait", Message: "has to be a number of seconds between 0 and " + strconv.Itoa(pollingMaxWaitSeconds)})
+			return
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+	timedOut := wait == 0
+
+	for {
+		body, etag, changed, err := readListForPolling(listUuid, public)
+		if err != nil {
+			abortWithActionError(context, err)
+			return
+		}
+
+		context.Header("ETag", etag)
+		context.Header("Cache-Control", "no-cache")
+		if !etagMatches(context.GetHeader("If-None-Match"), etag) {
+			context.Data(http.StatusOK, "application/json; charset=utf-8", body)
+			return
+		}
+		if timedOut {
+			context.Status(http.StatusNotModified)
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-timeout.C:
+			timedOut = true
+		case <-context.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// Encode the current revision of a list, and get a channel that is closed on its next change
+func readListForPolling(listUuid uuid.UUID, public bool) ([]byte, string, <-chan struct{}, error) {
+	listsMutex.Lock()
+	defer listsMutex.Unlock()
+
+	listEntry, present := lists[listUuid]
+	if !present || (public && !listEntry.Visibility.accessible()) {
+		return nil, "", nil, errListNotFound
+	}
+
+	body, err := json.Marshal(listEntry.response(time.Now()))
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return body, listETag(listEntry.Revision), listChanged(listUuid), nil
+}
@@ -0,0 +1,126 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Send a conditional request for a list
+func pollListRequest(router http.Handler, path string, etag string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	if etag != "" {
+		request.Header.Set("If-None-Match", etag)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// Wait until a request waits for a change of a list
+func waitForPoller(t *testing.T, listUuid uuid.UUID) {
+	t.Helper()
+
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+		listsMutex.Lock()
+		_, waiting := listChangeWaiters[listUuid]
+		listsMutex.Unlock()
+		if waiting {
+			return
+		}
+	}
+	t.Fatal("No request waits for a change of the list")
+}
+
+func TestListETag(t *testing.T) {
+	router := setupTestRouter(t)
+	listUuid, groupUuid, _ := addTestList(VisibilityPublic)
+	path := "/v1/public/list/" + listUuid.String()
+
+	response := pollListRequest(router, path, "")
+	etag := response.Header().Get("ETag")
+	if response.Code != http.StatusOK || etag == "" {
+		t.Fatalf("Retrieving the list returned status %d with ETag %q", response.Code, etag)
+	}
+
+	if response := pollListRequest(router, path, etag); response.Code != http.StatusNotModified || response.Body.Len() != 0 {
+		t.Errorf("Retrieving an unchanged list returned status %d with body %q", response.Code, response.Body.String())
+	}
+
+	doRequest(router, http.MethodPost, path+"/group/"+groupUuid.String()+"/application", `{"name": "Bob"}`, "")
+	response = pollListRequest(router, path, etag)
+	if response.Code != http.StatusOK || response.Header().Get("ETag") == etag {
+		t.Errorf("Retrieving a changed list returned status %d with ETag %q", response.Code, response.Header().Get("ETag"))
+	}
+	if lists[listUuid].Revision != 1 {
+		t.Errorf("The revision is %d after a single change, expected 1", lists[listUuid].Revision)
+	}
+
+	token := login(t, router)
+	protected := httptest.NewRequest(http.MethodGet, "/v1/protected/list/"+listUuid.String(), nil)
+	protected.Header.Set("Authorization", "Bearer "+token)
+	protected.Header.Set("If-None-Match", response.Header().Get("ETag"))
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, protected)
+	if recorder.Code != http.StatusNotModified {
+		t.Errorf("Retrieving an unchanged list with authentication returned status %d", recorder.Code)
+	}
+}
+
+func TestListLongPolling(t *testing.T) {
+	router := setupTestRouter(t)
+	listUuid, groupUuid, _ := addTestList(VisibilityPublic)
+	path := "/v1/public/list/" + listUuid.String()
+	etag := pollListRequest(router, path, "").Header().Get("ETag")
+
+	responses := make(chan *httptest.ResponseRecorder)
+	go func() { responses <- pollListRequest(router, path+"?wait=10", etag) }()
+	waitForPoller(t, listUuid)
+
+	doRequest(router, http.MethodPost, path+"/group/"+groupUuid.String()+"/application", `{"name": "Bob"}`, "")
+	select {
+	case response := <-responses:
+		if response.Code != http.StatusOK {
+			t.Errorf("A waiting request returned status %d after a change", response.Code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("A waiting request did not return after a change")
+	}
+
+	etag = pollListRequest(router, path, "").Header().Get("ETag")
+	start := time.Now()
+	if response := pollListRequest(router, path+"?wait=1", etag); response.Code != http.StatusNotModified || time.Since(start) < time.Second {
+		t.Errorf("A waiting request returned status %d after %v without a change", response.Code, time.Since(start))
+	}
+
+	for _, wait := range []string{"-1", "61", "soon"} {
+		if response := pollListRequest(router, path+"?wait="+wait, etag); response.Code != http.StatusBadRequest {
+			t.Errorf("Waiting %s seconds returned status %d, expected %d", wait, response.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Get the latest contribution, whether it is still running or not
+func (list *TalkingList) lastContribution() (TalkingListContribution, bool) {
+	if list.CurrentContribution.InProgress {
+		return list.CurrentContribution, true
+	}
+	if len(list.PastContributions) > 0 {
+		return list.PastContributions[len(list.PastContributions)-1], true
+	}
+	return TalkingListContribution{}, false
+}
+
+// Get all groups of the list, ordered by name
+func (list *TalkingList) sortedGroups() []uuid.UUID {
+	groups := make([]uuid.UUID, 0, len(list.Groups))
+	for groupUuid := range list.Groups {
+		groups = append(groups, groupUuid)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := list.Groups[groups[i]].Name, list.Groups[groups[j]].Name
+		if a != b {
+			return a < b
+		}
+		return groups[i].String() < groups[j].String()
+	})
+	return groups
+}
+
+// Get the groups taking turns in round-robin and alternating mode.
+// Configured groups that have been deleted are skipped, if none is left all groups take turns.
+func (list *TalkingList) queueGroupCycle() []uuid.UUID {
+	var cycle []uuid.UUID
+	for _, groupUuid := range list.QueuePolicy.Groups {
+		if _, entryPresent := list.Groups[groupUuid]; entryPresent {
+			cycle = append(cycle, groupUuid)
+		}
+	}
+	if len(cycle) == 0 {
+		return list.sortedGroups()
+	}
+	return cycle
+}
+
+// Get the first application in the queue of a group that is accepted by the filter
+func (list *TalkingList) groupSpeaker(groupUuid uuid.UUID, accept func(TalkingListApplication) bool) (TalkingListSpeaker, bool) {
+	groupEntry := list.Groups[groupUuid]
+	for _, applicationUuid := range groupEntry.queue() {
+		if applicationEntry := groupEntry.Applications[applicationUuid]; accept(applicationEntry) {
+			return TalkingListSpeaker{
+				GroupUuid:       groupUuid,
+				ApplicationUuid: applicationUuid,
+				Application:     applicationEntry,
+			}, true
+		}
+	}
+	return TalkingListSpeaker{}, false
+}
+
+// Check whether the person of an application has already spoken on the current agenda item.
+// People are recognised by their attendee or, if not linked to one, by their name.
+func (list *TalkingList) hasSpoken(application TalkingListApplication) bool {
+	contributions := list.PastContributions
+	if list.CurrentContribution.InProgress {
+		contributions = append(contributions[:len(contributions):len(contributions)], list.CurrentContribution)
+	}
+
+	for _, contribution := range contributions {
+		if !sameAgendaItem(contribution.AgendaItemUuid, list.Agenda.Current) {
+			continue
+		}
+
+		speaker := contribution.Application
+		if application.AttendeeUuid != nil && speaker.AttendeeUuid != nil {
+			if *application.AttendeeUuid == *speaker.AttendeeUuid {
+				return true
+			}
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(application.Name), strings.TrimSpace(speaker.Name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check whether an application goes ahead of repeat speakers
+func (list *TalkingList) prioritised(application TalkingListApplication) bool {
+	return list.QueuePolicy.FirstTimeSpeakersFirst && !list.hasSpoken(application)
+}
+
+// Get the application to be called next according to the queue policy of the list.
+// Applications of the priority types go first, in the order of submission.
+// In quota mode, FLINTA* speakers and others alternate. If nobody of the category
+// whose turn it is applied, somebody of the other category is called.
+// Within that, first-time speakers go ahead of repeat speakers if the list asks for it.
+func (list *TalkingList) nextSpeaker() (TalkingListSpeaker, bool) {
+	priorityTypes := list.QueuePolicy.priorityTypes()
+	for _, priorityType := range priorityTypes {
+		next, ok := list.firstSubmittedSpeaker(func(application TalkingListApplication) bool {
+			return application.applicationType() == priorityType
+		})
+		if ok {
+			next.Prioritised = list.prioritised(next.Application)
+			return next, true
+		}
+	}
+
+	// Every filter of a stage is tried in order, until one accepts an application
+	everybody := func(TalkingListApplication) bool { return true }
+	categories := []func(TalkingListApplication) bool{everybody}
+	if list.QueuePolicy.Quota {
+		// FLINTA* speakers start, and follow everybody else
+		turn := QuotaCategoryFlinta
+		if last, ok := list.lastContribution(); ok && last.Application.quotaCategory() == QuotaCategoryFlinta {
+			turn = QuotaCategoryOpen
+		}
+		categories = []func(TalkingListApplication) bool{
+			func(application TalkingListApplication) bool { return application.quotaCategory() == turn },
+			func(application TalkingListApplication) bool { return application.quotaCategory() != turn },
+		}
+	}
+	speakers := []func(TalkingListApplication) bool{everybody}
+	if list.QueuePolicy.FirstTimeSpeakersFirst {
+		speakers = []func(TalkingListApplication) bool{
+			func(application TalkingListApplication) bool { return !list.hasSpoken(application) },
+			list.hasSpoken,
+		}
+	}
+
+	for _, category := range categories {
+		for _, speaker := range speakers {
+			next, ok := list.nextSpeakerAccepted(func(application TalkingListApplication) bool {
+				return category(application) && speaker(application) && !slices.Contains(priorityTypes, application.applicationType())
+			})
+			if ok {
+				next.Prioritised = list.prioritised(next.Application)
+				return next, true
+			}
+		}
+	}
+	return TalkingListSpeaker{}, false
+}
+
+// Get the application to be called next among those accepted by the filter,
+// according to the queue policy of the list
+func (list *TalkingList) nextSpeakerAccepted(accept func(TalkingListApplication) bool) (TalkingListSpeaker, bool) {
+	switch list.QueuePolicy.Policy {
+	case QueuePolicyRoundRobin, QueuePolicyAlternating:
+		// The first group with applications after the group of the latest contribution takes its turn
+		cycle := list.queueGroupCycle()
+		lastIndex := -1
+		if last, ok := list.lastContribution(); ok {
+			for index, groupUuid := range cycle {
+				if groupUuid == last.GroupUuid {
+					lastIndex = index
+				}
+			}
+		}
+
+		for offset := 1; offset <= len(cycle); offset++ {
+			if speaker, ok := list.groupSpeaker(cycle[(lastIndex+offset)%len(cycle)], accept); ok {
+				return speaker, true
+			}
+		}
+		return TalkingListSpeaker{}, false
+
+	default:
+		return list.firstSubmittedSpeaker(accept)
+	}
+}
+
+// Get the application submitted first among the fronts of all queues, only considering
+// applications accepted by the filter
+func (list *TalkingList) firstSubmittedSpeaker(accept func(TalkingListApplication) bool) (TalkingListSpeaker, bool) {
+	var next TalkingListSpeaker
+	found := false
+	for _, groupUuid := range list.sortedGroups() {
+		speaker, ok := list.groupSpeaker(groupUuid, accept)
+		if ok && (!found || speaker.Application.SubmittedAt.Before(next.Application.SubmittedAt)) {
+			next, found = speaker, true
+		}
+	}
+	return next, found
+}
+
+// Check whether another application of its kind may be submitted now.
+// Direct replies refer to the running contribution, points of order are limited while waiting.
+func (list *TalkingList) checkApplicationLimit(application TalkingListApplication) error {
+	applicationType := application.applicationType()
+	limit := list.QueuePolicy.applicationLimit(applicationType)
+	if limit < 0 {
+		return nil
+	}
+
+	if applicationType == ApplicationTypeDirectReply && !list.CurrentContribution.InProgress {
+		return errNoContributionToReplyTo
+	}
+
+	waiting := 0
+	for _, groupEntry := range list.Groups {
+		for _, applicationEntry := range groupEntry.Applications {
+			if applicationEntry.applicationType() != applicationType {
+				continue
+			}
+			if applicationType == ApplicationTypeDirectReply && applicationEntry.SubmittedAt.Before(list.CurrentContribution.StartTime) {
+				continue
+			}
+			waiting++
+		}
+	}
+
+	if waiting >= limit {
+		return errApplicationLimitReached
+	}
+	return nil
+}
+
+// Calculate the order in which all applications will be called,
+// if nobody applies or withdraws in the meantime
+func (list *TalkingList) speakingOrder() []TalkingListSpeaker {
+	// Work on a copy of the queues and contributions, the running contribution counts as finished.
+	// The agenda is only read, so it does not need to be copied.
+	simulation := TalkingList{
+		Groups:            make(map[uuid.UUID]TalkingListGroup, len(list.Groups)),
+		PastContributions: append([]TalkingListContribution(nil), list.PastContributions...),
+		QueuePolicy:       list.QueuePolicy,
+		Agenda:            list.Agenda,
+	}
+	if list.CurrentContribution.InProgress {
+		simulation.PastContributions = append(simulation.PastContributions, list.CurrentContribution)
+	}
+	for groupUuid, groupEntry := range list.Groups {
+		applications := make(map[uuid.UUID]TalkingListApplication, len(groupEntry.Applications))
+		for applicationUuid, applicationEntry := range groupEntry.Applications {
+			applications[applicationUuid] = applicationEntry
+		}
+		groupEntry.Applications = applications
+		simulation.Groups[groupUuid] = groupEntry
+	}
+
+	order := []TalkingListSpeaker{}
+	for {
+		speaker, ok := simulation.nextSpeaker()
+		if !ok {
+			return order
+		}
+
+		order = append(order, speaker)
+		delete(simulation.Groups[speaker.GroupUuid].Applications, speaker.ApplicationUuid)
+		simulation.PastContributions = append(simulation.PastContributions, TalkingListContribution{
+			Application:    speaker.Application,
+			GroupUuid:      speaker.GroupUuid,
+			AgendaItemUuid: list.currentAgendaItem(),
+		})
+	}
+}
+
+// Check that a queue policy can be applied to the list
+func (list *TalkingList) validateQueuePolicy(policy TalkingListQueuePolicy) []APIFieldError {
+	var fields []APIFieldError
+	for _, groupUuid := range policy.Groups {
+		if _, entryPresent := list.Groups[groupUuid]; !entryPresent {
+			fields = append(fields, APIFieldError{Field: "groups", Message: "contains the unknown group " + groupUuid.String()})
+		}
+	}
+	if policy.Policy == QueuePolicyAlternating && len(policy.Groups) != 2 {
+		fields = append(fields, APIFieldError{Field: "groups", Message: "must contain exactly two groups to alternate between"})
+	}
+	return fields
+}
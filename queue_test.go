@@ -0,0 +1,388 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Retrieve the queue of a group through the public endpoint and return the names in order
+func queueNames(t *testing.T, router *gin.Engine, groupPath string) string {
+	t.Helper()
+
+	response := doRequest(router, http.MethodGet, "/v1/public"+groupPath+"/application", "", "")
+	var queue []TalkingListQueuedApplication
+	if err := json.Unmarshal(response.Body.Bytes(), &queue); err != nil {
+		t.Fatalf("Decoding the queue failed: %v", err)
+	}
+
+	names := make([]string, 0, len(queue))
+	for index, application := range queue {
+		if application.Position != index+1 {
+			t.Errorf("%s is at index %d, but has position %d", application.Name, index, application.Position)
+		}
+		names = append(names, application.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestApplicationsAreQueuedInOrder(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+	listUuid, groupUuid, _ := addTestList(VisibilityPublic)
+	groupPath := "/list/" + listUuid.String() + "/group/" + groupUuid.String()
+
+	// The application of the test list has no position, like those of older databases
+	applications := make(map[string]uuid.UUID)
+	for _, name := range []string{"Bob", "Carol", "Dave"} {
+		response := doRequest(router, http.MethodPost, "/v1/public"+groupPath+"/application", `{"name": "`+name+`", "position": 1}`, "")
+		var created struct {
+			Uuid uuid.UUID `json:"uuid"`
+		}
+		if err := json.Unmarshal(response.Body.Bytes(), &created); err != nil {
+			t.Fatalf("Creating the application of %s failed: %s", name, response.Body.String())
+		}
+		applications[name] = created.Uuid
+	}
+	if names := queueNames(t, router, groupPath); names != "Alice,Bob,Carol,Dave" {
+		t.Errorf("The queue is %s, expected the order of submission", names)
+	}
+
+	moves := []struct {
+		name     string
+		endpoint string
+		body     string
+		expected string
+	}{
+		{"Dave", "/move_to_top", "", "Dave,Alice,Bob,Carol"},
+		{"Dave", "/move_to_bottom", "", "Alice,Bob,Carol,Dave"},
+		{"Carol", "/position", `{"position": 2}`, "Alice,Carol,Bob,Dave"},
+		{"Bob", "/position", `{"position": 99}`, "Alice,Carol,Dave,Bob"},
+	}
+	for _, move := range moves {
+		path := "/v1/protected" + groupPath + "/application/" + applications[move.name].String() + move.endpoint
+		response := doRequest(router, http.MethodPost, path, move.body, token)
+		if response.Code != http.StatusOK {
+			t.Errorf("Moving %s with %s returned status %d", move.name, move.endpoint, response.Code)
+		}
+		if names := queueNames(t, router, groupPath); names != move.expected {
+			t.Errorf("After moving %s with %s the queue is %s, expected %s", move.name, move.endpoint, names, move.expected)
+		}
+	}
+
+	response := doRequest(router, http.MethodPost, "/v1/protected"+groupPath+"/application/"+applications["Bob"].String()+"/position", `{"position": 0}`, token)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Moving to position 0 returned status %d, expected %d", response.Code, http.StatusBadRequest)
+	}
+
+	// The remaining applications move up when one is withdrawn
+	doRequest(router, http.MethodDelete, "/v1/public"+groupPath+"/application/"+applications["Carol"].String(), "", "")
+	if names := queueNames(t, router, groupPath); names != "Alice,Dave,Bob" {
+		t.Errorf("After withdrawing Carol the queue is %s", names)
+	}
+}
+
+// Add a group with applications submitted one minute apart, starting at the given minute
+func addTestGroup(listUuid uuid.UUID, name string, firstMinute int, applicants ...string) uuid.UUID {
+	groupUuid := uuid.New()
+	group := TalkingListGroup{Name: name, Applications: make(map[uuid.UUID]TalkingListApplication)}
+	for index, applicant := range applicants {
+		submittedAt := time.Date(2022, 1, 1, 10, firstMinute+index, 0, 0, time.UTC)
+		group.Applications[uuid.New()] = TalkingListApplication{Name: applicant, SubmittedAt: submittedAt, Position: index + 1}
+	}
+
+	listEntry := lists[listUuid]
+	listEntry.Groups[groupUuid] = group
+	lists[listUuid] = listEntry
+	return groupUuid
+}
+
+func TestNextSpeakerFollowsQueuePolicy(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+
+	tests := []struct {
+		policy   string
+		expected string
+	}{
+		// Applications submitted at the same time are called in the order of the group names
+		{QueuePolicyFifo, "Anna,Anton,Bert,Alex,Berta,Carla"},
+		{QueuePolicyRoundRobin, "Anna,Bert,Carla,Anton,Berta,Alex"},
+		// Only the two configured groups take turns
+		{QueuePolicyAlternating, "Anna,Bert,Anton,Berta,Alex"},
+	}
+	for _, test := range tests {
+		listUuid := uuid.New()
+		lists[listUuid] = TalkingList{Name: "Test", Groups: make(map[uuid.UUID]TalkingListGroup)}
+		groupA := addTestGroup(listUuid, "A", 0, "Anna", "Anton", "Alex")
+		groupB := addTestGroup(listUuid, "B", 1, "Bert", "Berta")
+		addTestGroup(listUuid, "C", 3, "Carla")
+		listPath := "/v1/protected/list/" + listUuid.String()
+
+		policy := `{"policy": "` + test.policy + `", "groups": ["` + groupA.String() + `", "` + groupB.String() + `"]}`
+		if test.policy != QueuePolicyAlternating {
+			policy = `{"policy": "` + test.policy + `"}`
+		}
+		if response := doRequest(router, http.MethodPost, listPath+"/queue_policy", policy, token); response.Code != http.StatusOK {
+			t.Fatalf("Setting the policy %s returned status %d: %s", test.policy, response.Code, response.Body.String())
+		}
+
+		var called []string
+		var following *TalkingListSpeaker
+		for {
+			var next TalkingListNext
+			response := doRequest(router, http.MethodPost, listPath+"/next", "", token)
+			if err := json.Unmarshal(response.Body.Bytes(), &next); err != nil {
+				t.Fatalf("Decoding the next speaker failed: %s", response.Body.String())
+			}
+			if next.Current == nil {
+				break
+			}
+			if following != nil && following.Application.Name != next.Current.Application.Name {
+				t.Errorf("%s: %s was announced to follow, but %s was called", test.policy, following.Application.Name, next.Current.Application.Name)
+			}
+			called = append(called, next.Current.Application.Name)
+			following = next.Following
+		}
+
+		if strings.Join(called, ",") != test.expected {
+			t.Errorf("With the policy %s the speakers were %v, expected %s", test.policy, called, test.expected)
+		}
+		if lists[listUuid].CurrentContribution.InProgress || len(lists[listUuid].PastContributions) != len(called) {
+			t.Errorf("With the policy %s the last contribution was not stopped", test.policy)
+		}
+	}
+}
+
+func TestQueuePolicyIsValidated(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+	listUuid, groupUuid, _ := addTestList(VisibilityPublic)
+	path := "/v1/protected/list/" + listUuid.String() + "/queue_policy"
+
+	for _, body := range []string{
+		`{"policy": "lottery"}`,
+		`{"policy": "alternating", "groups": ["` + groupUuid.String() + `"]}`,
+		`{"policy": "round_robin", "groups": ["` + uuid.NewString() + `"]}`,
+	} {
+		if response := doRequest(router, http.MethodPost, path, body, token); response.Code != http.StatusBadRequest {
+			t.Errorf("Setting the queue policy %s returned status %d, expected %d", body, response.Code, http.StatusBadRequest)
+		}
+	}
+	if lists[listUuid].QueuePolicy.Policy != "" {
+		t.Errorf("An invalid request changed the queue policy to %+v", lists[listUuid].QueuePolicy)
+	}
+
+	numLists := len(lists)
+	body := `{"name": "Test", "queue_policy": {"policy": "alternating", "groups": ["` + groupUuid.String() + `", "` + uuid.NewString() + `"]}}`
+	if response := doRequest(router, http.MethodPost, "/v1/protected/list", body, token); response.Code != http.StatusBadRequest || len(lists) != numLists {
+		t.Errorf("Creating a list alternating between groups returned status %d, expected %d", response.Code, http.StatusBadRequest)
+	}
+	body = `{"name": "Test", "queue_policy": {"policy": "round_robin"}}`
+	if response := doRequest(router, http.MethodPost, "/v1/protected/list", body, token); response.Code != http.StatusCreated {
+		t.Errorf("Creating a list with a round-robin policy returned status %d, expected %d", response.Code, http.StatusCreated)
+	}
+}
+
+func TestQuotaModeAlternatesCategories(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+	listUuid, groupUuid, _ := addTestList(VisibilityPublic)
+	listPath := "/v1/protected/list/" + listUuid.String()
+	doRequest(router, http.MethodPost, listPath+"/queue_policy", `{"policy": "fifo", "quota": true}`, token)
+
+	// Alice of the test list applied first and has no category
+	groupPath := "/v1/public/list/" + listUuid.String() + "/group/" + groupUuid.String() + "/application"
+	for _, application := range []string{
+		`{"name": "Bob", "quota_category": "open"}`,
+		`{"name": "Fatima", "quota_category": "flinta"}`,
+		`{"name": "Dave"}`,
+		`{"name": "Frieda", "quota_category": "flinta"}`,
+	} {
+		if response := doRequest(router, http.MethodPost, groupPath, application, ""); response.Code != http.StatusCreated {
+			t.Fatalf("Applying with %s returned status %d", application, response.Code)
+		}
+	}
+	if response := doRequest(router, http.MethodPost, groupPath, `{"name": "Eve", "quota_category": "other"}`, ""); response.Code != http.StatusBadRequest {
+		t.Errorf("Applying with an unknown category returned status %d, expected %d", response.Code, http.StatusBadRequest)
+	}
+
+	var order []TalkingListSpeaker
+	response := doRequest(router, http.MethodGet, "/v1/public/list/"+listUuid.String()+"/speaking_order", "", "")
+	if err := json.Unmarshal(response.Body.Bytes(), &order); err != nil {
+		t.Fatalf("Decoding the speaking order failed: %s", response.Body.String())
+	}
+	var names []string
+	for _, speaker := range order {
+		names = append(names, speaker.Application.Name)
+	}
+	if strings.Join(names, ",") != "Fatima,Alice,Frieda,Bob,Dave" {
+		t.Errorf("The speaking order is %v", names)
+	}
+
+	for range order {
+		doRequest(router, http.MethodPost, listPath+"/next", "", token)
+	}
+	listEntry := lists[listUuid]
+	if len(listEntry.PastContributions) != 4 || listEntry.CurrentContribution.Application.Name != "Dave" {
+		t.Errorf("The speakers were called in a different order than announced: %+v", listEntry.PastContributions)
+	}
+
+	distribution := listEntry.timeDistribution()
+	if distribution.QuotaNumberContributions[QuotaCategoryFlinta] != 2 || distribution.QuotaNumberContributions[QuotaCategoryOpen] != 2 {
+		t.Errorf("The quota balance is %v", distribution.QuotaNumberContributions)
+	}
+
+	response = doRequest(router, http.MethodGet, listPath+"/mdreport", "", token)
+	if !strings.Contains(response.Body.String(), "## Quotierung") || !strings.Contains(response.Body.String(), "| FLINTA* | 2 |") {
+		t.Errorf("The report does not contain the quota balance:\n%s", response.Body.String())
+	}
+}
+
+func TestFirstTimeSpeakersGoFirst(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+	listUuid, groupUuid, applicationUuid := addTestList(VisibilityPublic)
+	listPath := "/v1/protected/list/" + listUuid.String()
+	groupPath := "/v1/public/list/" + listUuid.String() + "/group/" + groupUuid.String() + "/application"
+
+	attendeeUuid := uuid.New()
+	listEntry := lists[listUuid]
+	listEntry.Attendees = map[uuid.UUID]TalkingListAttendee{attendeeUuid: {GivenName: "Carol", SurName: "C", Degree: "Informatik"}}
+	delete(listEntry.Groups[groupUuid].Applications, applicationUuid)
+	listEntry.PastContributions = []TalkingListContribution{
+		{Application: TalkingListApplication{Name: "Alice"}, GroupUuid: groupUuid},
+		{Application: TalkingListApplication{Name: "Carol C.", AttendeeUuid: &attendeeUuid}, GroupUuid: groupUuid},
+	}
+	lists[listUuid] = listEntry
+
+	doRequest(router, http.MethodPost, listPath+"/queue_policy", `{"first_time_speakers_first": true}`, token)
+	for _, application := range []string{
+		`{"name": " alice "}`,
+		`{"name": "Bob"}`,
+		`{"name": "Caro", "attendee_uuid": "` + attendeeUuid.String() + `"}`,
+		`{"name": "Dave"}`,
+	} {
+		if response := doRequest(router, http.MethodPost, groupPath, application, ""); response.Code != http.StatusCreated {
+			t.Fatalf("Applying with %s returned status %d", application, response.Code)
+		}
+	}
+	if response := doRequest(router, http.MethodPost, groupPath, `{"name": "Eve", "attendee_uuid": "`+uuid.NewString()+`"}`, ""); response.Code != http.StatusBadRequest {
+		t.Errorf("Applying as an unknown attendee returned status %d, expected %d", response.Code, http.StatusBadRequest)
+	}
+
+	var queue []TalkingListQueuedApplication
+	response := doRequest(router, http.MethodGet, groupPath, "", "")
+	if err := json.Unmarshal(response.Body.Bytes(), &queue); err != nil {
+		t.Fatalf("Decoding the queue failed: %v", err)
+	}
+	var flags []string
+	for _, application := range queue {
+		flags = append(flags, application.Name+"="+map[bool]string{true: "first", false: "repeat"}[application.Prioritised])
+	}
+	if strings.Join(flags, ",") != " alice =repeat,Bob=first,Caro=repeat,Dave=first" {
+		t.Errorf("The queue is marked as %v", flags)
+	}
+
+	var order []TalkingListSpeaker
+	response = doRequest(router, http.MethodGet, "/v1/public/list/"+listUuid.String()+"/speaking_order", "", "")
+	if err := json.Unmarshal(response.Body.Bytes(), &order); err != nil {
+		t.Fatalf("Decoding the speaking order failed: %v", err)
+	}
+	var names []string
+	for _, speaker := range order {
+		names = append(names, speaker.Application.Name)
+	}
+	if strings.Join(names, ",") != "Bob,Dave, alice ,Caro" {
+		t.Errorf("The speaking order is %v", names)
+	}
+}
+
+func TestTypedApplicationsJumpTheQueue(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+	listUuid, groupUuid, _ := addTestList(VisibilityPublic)
+	listPath := "/v1/protected/list/" + listUuid.String()
+	groupPath := "/v1/public/list/" + listUuid.String() + "/group/" + groupUuid.String() + "/application"
+
+	if response := doRequest(router, http.MethodPost, groupPath, `{"name": "Zoe", "type": "direct_reply"}`, ""); response.Code != http.StatusConflict {
+		t.Errorf("A direct reply while nobody is speaking returned status %d, expected %d", response.Code, http.StatusConflict)
+	}
+	doRequest(router, http.MethodPost, listPath+"/next", "", token)
+
+	applications := []struct {
+		body   string
+		status int
+	}{
+		{`{"name": "Bob"}`, http.StatusCreated},
+		{`{"name": "Carol", "type": "direct_reply"}`, http.StatusCreated},
+		{`{"name": "Dave", "type": "direct_reply"}`, http.StatusConflict},
+		{`{"name": "Erik", "type": "point_of_order"}`, http.StatusCreated},
+		{`{"name": "Frank", "type": "point_of_order"}`, http.StatusConflict},
+		{`{"name": "Gina", "type": "motion"}`, http.StatusBadRequest},
+	}
+	for _, application := range applications {
+		if response := doRequest(router, http.MethodPost, groupPath, application.body, ""); response.Code != application.status {
+			t.Errorf("Applying with %s returned status %d, expected %d", application.body, response.Code, application.status)
+		}
+	}
+
+	var order []TalkingListSpeaker
+	response := doRequest(router, http.MethodGet, "/v1/public/list/"+listUuid.String()+"/speaking_order", "", "")
+	if err := json.Unmarshal(response.Body.Bytes(), &order); err != nil {
+		t.Fatalf("Decoding the speaking order failed: %v", err)
+	}
+	var names []string
+	for _, speaker := range order {
+		names = append(names, speaker.Application.Name)
+	}
+	if strings.Join(names, ",") != "Erik,Carol,Bob" {
+		t.Errorf("The speaking order is %v", names)
+	}
+
+	for range order {
+		doRequest(router, http.MethodPost, listPath+"/next", "", token)
+	}
+	doRequest(router, http.MethodPost, listPath+"/stop_contribution", "", token)
+
+	listEntry := lists[listUuid]
+	distribution := listEntry.timeDistribution()
+	expected := map[string]uint{ApplicationTypeRegular: 2, ApplicationTypeDirectReply: 1, ApplicationTypePointOfOrder: 1}
+	for applicationType, number := range expected {
+		if distribution.TypeNumberContributions[applicationType] != number {
+			t.Errorf("The distribution by kind is %v, expected %v", distribution.TypeNumberContributions, expected)
+			break
+		}
+	}
+
+	response = doRequest(router, http.MethodGet, listPath+"/mdreport", "", token)
+	if !strings.Contains(response.Body.String(), "| GO-Antrag | 1 |") {
+		t.Errorf("The report does not contain the distribution by kind:\n%s", response.Body.String())
+	}
+}
@@ -0,0 +1,218 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	_ "embed"
+	"io"
+	"math"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hako/durafmt"
+)
+
+// The template of the Markdown report, embedded at build time
+//
+//go:embed report.got
+var reportTemplateSource string
+
+// Calculate the percentage of a share of the total time, rounded down to two decimal places.
+// Without any speaking time, all shares are zero.
+func relativeShare(share time.Duration, total time.Duration) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Floor(((share.Seconds()/total.Seconds())*100)*100) / 100
+}
+
+// Fill the report template with the data of a talking list and write it to w
+func renderReport(w io.Writer, listEntry TalkingList) error {
+	distribution := listEntry.timeDistribution()
+
+	// Combine all time distribution data into a structure for use in the template
+	type GroupTimeDistribution struct {
+		GroupName         string
+		NumContributions  uint
+		TimeShareAbsolute time.Duration
+		TimeShareRelative float64
+	}
+	groupTimeDistributions := make(map[uuid.UUID]GroupTimeDistribution)
+	for uuid := range listEntry.Groups {
+		var groupTimeDistribution GroupTimeDistribution
+
+		groupTimeDistribution.GroupName = listEntry.Groups[uuid].Name
+		groupTimeDistribution.NumContributions = distribution.NumberContributions[uuid]
+		groupTimeDistribution.TimeShareAbsolute = distribution.TimeShare[uuid]
+		groupTimeDistribution.TimeShareRelative = relativeShare(distribution.TimeShare[uuid], distribution.TotalTime)
+
+		groupTimeDistributions[uuid] = groupTimeDistribution
+	}
+
+	// The distribution between quota categories and kinds of applications, in a fixed order
+	type CategoryTimeDistribution struct {
+		Category          string
+		NumContributions  uint
+		TimeShareAbsolute time.Duration
+		TimeShareRelative float64
+	}
+	type category struct{ key, name string }
+	categoryTimeDistributions := func(categories []category, timeShare map[string]time.Duration, numberContributions map[string]uint) []CategoryTimeDistribution {
+		var categoryTimeDistributions []CategoryTimeDistribution
+		for _, category := range categories {
+			categoryTimeDistributions = append(categoryTimeDistributions, CategoryTimeDistribution{
+				Category:          category.name,
+				NumContributions:  numberContributions[category.key],
+				TimeShareAbsolute: timeShare[category.key],
+				TimeShareRelative: relativeShare(timeShare[category.key], distribution.TotalTime),
+			})
+		}
+		return categoryTimeDistributions
+	}
+	quotaTimeDistributions := categoryTimeDistributions(
+		[]category{{QuotaCategoryFlinta, "FLINTA*"}, {QuotaCategoryOpen, "offen"}},
+		distribution.QuotaTimeShare, distribution.QuotaNumberContributions)
+	typeTimeDistributions := categoryTimeDistributions(
+		[]category{{ApplicationTypeRegular, "Redebeitrag"}, {ApplicationTypeDirectReply, "Direkte Antwort"}, {ApplicationTypePointOfOrder, "GO-Antrag"}},
+		distribution.TypeTimeShare, distribution.TypeNumberContributions)
+
+	// The distribution within each agenda item, in the order of the agenda
+	type AgendaItemTimeDistribution struct {
+		Number            int
+		Title             string
+		NumContributions  uint
+		TimeShareAbsolute time.Duration
+		TimeShareRelative float64
+		Groups            []GroupTimeDistribution
+	}
+	var agendaTimeDistributions []AgendaItemTimeDistribution
+	for index, itemDistribution := range distribution.Agenda {
+		agendaTimeDistribution := AgendaItemTimeDistribution{
+			Number:            index + 1,
+			Title:             listEntry.Agenda.Items[index].Title,
+			TimeShareAbsolute: itemDistribution.TotalTime,
+			TimeShareRelative: relativeShare(itemDistribution.TotalTime, distribution.TotalTime),
+		}
+		for _, groupUuid := range listEntry.sortedGroups() {
+			agendaTimeDistribution.NumContributions += itemDistribution.NumberContributions[groupUuid]
+			agendaTimeDistribution.Groups = append(agendaTimeDistribution.Groups, GroupTimeDistribution{
+				GroupName:         listEntry.Groups[groupUuid].Name,
+				NumContributions:  itemDistribution.NumberContributions[groupUuid],
+				TimeShareAbsolute: itemDistribution.TimeShare[groupUuid],
+				TimeShareRelative: relativeShare(itemDistribution.TimeShare[groupUuid], itemDistribution.TotalTime),
+			})
+		}
+		agendaTimeDistributions = append(agendaTimeDistributions, agendaTimeDistribution)
+	}
+
+	// The motions in the order they were created, with their state in words
+	type MotionReport struct {
+		Number     int
+		Text       string
+		Mover      string
+		AgendaItem string
+		Status     string
+		Votes      *TalkingListVotes
+		Result     string
+	}
+	motionStatusNames := map[string]string{MotionStatusDraft: "nicht abgestimmt", MotionStatusOpen: "Abstimmung läuft", MotionStatusClosed: "abgestimmt"}
+	motionResultNames := map[string]string{MotionResultAccepted: "**angenommen**", MotionResultRejected: "**abgelehnt**"}
+	var motionReports []MotionReport
+	for index, motionUuid := range listEntry.sortedMotions() {
+		motionEntry := listEntry.Motions[motionUuid]
+		motionReport := MotionReport{
+			Number: index + 1,
+			Text:   motionEntry.Text,
+			Mover:  motionEntry.Mover,
+			Status: motionStatusNames[motionEntry.Status],
+			Votes:  motionEntry.Votes,
+			Result: motionResultNames[motionEntry.Result],
+		}
+		if itemIndex := listEntry.agendaIndex(motionEntry.AgendaItemUuid); itemIndex >= 0 {
+			motionReport.AgendaItem = "TOP " + strconv.Itoa(itemIndex+1) + ": " + listEntry.Agenda.Items[itemIndex].Title
+		}
+		motionReports = append(motionReports, motionReport)
+	}
+
+	reportTemplate, err := template.New("report.got").Funcs(template.FuncMap{
+		"prettyDuration": func(duration time.Duration) string {
+			return durafmt.Parse(duration).LimitFirstN(1).String()
+		},
+		"getGroupName": func(uuid uuid.UUID) string {
+			groupEntry, entryPresent := listEntry.Groups[uuid]
+			if !entryPresent {
+				return ""
+			}
+			return groupEntry.Name
+		},
+		"timeDistribution": func(uuid uuid.UUID) GroupTimeDistribution {
+			return groupTimeDistributions[uuid]
+		},
+		"speakingTimeNote": func(contribution TalkingListContribution) string {
+			switch {
+			case contribution.TimeLimit == 0:
+				return ""
+			case contribution.Overrun:
+				return "**überschritten** (" + durafmt.Parse(contribution.TimeLimit).String() + ")"
+			case contribution.AutoStopped:
+				return "automatisch beendet (" + durafmt.Parse(contribution.TimeLimit).String() + ")"
+			default:
+				return "eingehalten (" + durafmt.Parse(contribution.TimeLimit).String() + ")"
+			}
+		},
+		"pauseNote": func(contribution TalkingListContribution) string {
+			if len(contribution.Pauses) == 0 {
+				return ""
+			}
+			return strconv.Itoa(len(contribution.Pauses)) + "× (" + durafmt.Parse(contribution.pausedTime(contribution.EndTime)).LimitFirstN(1).String() + ")"
+		},
+		"numberOverruns": func() int {
+			overruns := 0
+			for _, contribution := range listEntry.PastContributions {
+				if contribution.Overrun {
+					overruns++
+				}
+			}
+			return overruns
+		},
+		"quotaDistribution": func() []CategoryTimeDistribution {
+			return quotaTimeDistributions
+		},
+		"typeDistribution": func() []CategoryTimeDistribution {
+			return typeTimeDistributions
+		},
+		"agendaDistribution": func() []AgendaItemTimeDistribution {
+			return agendaTimeDistributions
+		},
+		"motions": func() []MotionReport {
+			return motionReports
+		},
+		"timeNow": time.Now,
+	}).Parse(reportTemplateSource)
+	if err != nil {
+		return err
+	}
+
+	return reportTemplate.Execute(w, listEntry)
+}
@@ -22,700 +22,1426 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"math"
 	"net/http"
-	"text/template"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/hako/durafmt"
 )
 
+// Register all endpoints of the current API version
 func setupRoutes(public *gin.RouterGroup, protected *gin.RouterGroup) {
-	// Retrieve all talking lists currently known to the application
-	public.GET("/list", func(context *gin.Context) {
-		listsFiltered := make(map[uuid.UUID]TalkingList)
-
-		// Filter lists so only public lists are returned
-		for key, list := range lists {
-			if list.Visibility == 2 {
-				listsFiltered[key] = list
-			}
-		}
+	public.GET("/list", getPublicLists)
+
+	// GET /list/:uuid supports long polling, see setupPollingRoutes
+	publicList := public.Group("/list/:uuid", publicListAccess())
+	publicList.GET("/group", getGroups)
+	publicList.GET("/group/:group_uuid", getGroup)
+	publicList.GET("/time_distribution", getTimeDistribution)
+	publicList.GET("/timer", getTimer)
+	publicList.GET("/speaking_order", getSpeakingOrder)
+	publicList.GET("/agenda", getAgenda)
+	publicList.GET("/agenda/:agenda_item_uuid", getAgendaItem)
+	publicList.GET("/motion", getMotions)
+	publicList.GET("/motion/:motion_uuid", getMotion)
+	publicList.GET("/group/:group_uuid/application", getApplications)
+	publicList.GET("/group/:group_uuid/application/:application_uuid", getApplication)
+	publicList.POST("/group/:group_uuid/application", createApplication)
+	publicList.DELETE("/group/:group_uuid/application/:application_uuid", deleteApplication)
+
+	protected.GET("/list", getLists)
+	protected.POST("/list", createList)
+	protected.DELETE("/list/:uuid", deleteList)
+	protected.POST("/list/:uuid/visibility", updateVisibility)
+	protected.POST("/list/:uuid/speaking_time", updateSpeakingTime)
+	protected.POST("/list/:uuid/queue_policy", updateQueuePolicy)
+	protected.GET("/list/:uuid/agenda", getAgenda)
+	protected.POST("/list/:uuid/agenda", createAgendaItem)
+	protected.GET("/list/:uuid/agenda/:agenda_item_uuid", getAgendaItem)
+	protected.POST("/list/:uuid/agenda/:agenda_item_uuid", updateAgendaItem)
+	protected.DELETE("/list/:uuid/agenda/:agenda_item_uuid", deleteAgendaItem)
+	protected.POST("/list/:uuid/agenda/:agenda_item_uuid/position", moveAgendaItem)
+	protected.POST("/list/:uuid/agenda/:agenda_item_uuid/current", setCurrentAgendaItem)
+	protected.POST("/list/:uuid/next_agenda_item", nextAgendaItem)
+	protected.GET("/list/:uuid/motion", getMotions)
+	protected.GET("/list/:uuid/motion/:motion_uuid", getMotion)
+	protected.POST("/list/:uuid/motion", createMotion)
+	protected.DELETE("/list/:uuid/motion/:motion_uuid", deleteMotion)
+	protected.POST("/list/:uuid/motion/:motion_uuid/open", openMotion)
+	protected.POST("/list/:uuid/motion/:motion_uuid/close", closeMotion)
+	protected.GET("/list/:uuid/group", getGroups)
+	protected.GET("/list/:uuid/group/:group_uuid", getGroup)
+	protected.POST("/list/:uuid/group", createGroup)
+	protected.DELETE("/list/:uuid/group/:group_uuid", deleteGroup)
+	protected.POST("/list/:uuid/group/:group_uuid/speaking_time", updateGroupSpeakingTime)
+	protected.GET("/list/:uuid/group/:group_uuid/application", getApplications)
+	protected.POST("/list/:uuid/group/:group_uuid/application/:application_uuid/position", repositionApplication)
+	protected.POST("/list/:uuid/group/:group_uuid/application/:application_uuid/move_to_top", moveApplicationToTop)
+	protected.POST("/list/:uuid/group/:group_uuid/application/:application_uuid/move_to_bottom", moveApplicationToBottom)
+	protected.GET("/list/:uuid/timer", getTimer)
+	protected.GET("/list/:uuid/speaking_order", getSpeakingOrder)
+	protected.POST("/list/:uuid/reset_past_contributions", resetPastContributions)
+	protected.POST("/list/:uuid/contribution/:contribution_uuid", updateContribution)
+	protected.DELETE("/list/:uuid/contribution/:contribution_uuid", deleteContribution)
+	protected.POST("/list/:uuid/start_contribution", startContribution)
+	protected.POST("/list/:uuid/stop_contribution", stopContribution)
+	protected.POST("/list/:uuid/pause_contribution", pauseContribution)
+	protected.POST("/list/:uuid/resume_contribution", resumeContribution)
+	protected.POST("/list/:uuid/next", nextContribution)
+	protected.POST("/list/:uuid/undo", undoContribution)
+	protected.GET("/list/:uuid/attendee", getAttendees)
+	protected.GET("/list/:uuid/attendee/:attendee_uuid", getAttendee)
+	protected.POST("/list/:uuid/attendee", createAttendee)
+	protected.DELETE("/list/:uuid/attendee/:attendee_uuid", deleteAttendee)
+	protected.GET("/list/:uuid/mdreport", getMarkdownReport)
+}
 
-		context.JSON(http.StatusOK, listsFiltered)
-	})
-
-	// Retrieve all talking lists currently known to the application
-	// In contrast to the public endpoint, this will also retrieve
-	// private lists
-	protected.GET("/list", func(context *gin.Context) {
-		context.JSON(http.StatusOK, lists)
-	})
-
-	// Retrieve a specific talking list
-	public.GET("/list/:uuid", func(context *gin.Context) {
-		listUuid, err := uuid.Parse(context.Param("uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+// Register the endpoints of the unversioned API.
+// They are deprecated aliases of the endpoints in /v1 and will be removed in the future.
+// Note that state changes use GET here.
+func setupLegacyRoutes(public *gin.RouterGroup, protected *gin.RouterGroup) {
+	public.Use(deprecatedRoute())
+	protected.Use(deprecatedRoute())
+
+	public.GET("/list", getPublicLists)
+
+	publicList := public.Group("/list/:uuid", publicListAccess())
+	publicList.GET("", getList)
+	publicList.GET("/group", getGroups)
+	publicList.GET("/group/:group_uuid", getGroup)
+	publicList.GET("/time_distribution", getTimeDistribution)
+	publicList.GET("/group/:group_uuid/application", getApplicationMap)
+	publicList.POST("/group/:group_uuid/application", createApplication)
+	publicList.DELETE("/group/:group_uuid/application/:application_uuid", deleteApplication)
+
+	protected.GET("/list", getLists)
+	protected.GET("/list/:uuid", getList)
+	protected.POST("/list", createList)
+	protected.POST("/list/:uuid/visibility", updateVisibility)
+	protected.DELETE("/list/:uuid", deleteList)
+	protected.POST("/list/:uuid/group", createGroup)
+	protected.DELETE("/list/:uuid/group/:group_uuid", deleteGroup)
+	protected.GET("/list/:uuid/reset_past_contributions", resetPastContributions)
+	protected.GET("/list/:uuid/start_contribution", startContributionLegacy)
+	protected.GET("/list/:uuid/stop_contribution", stopContribution)
+	protected.GET("/list/:uuid/attendee", getAttendees)
+	protected.GET("/list/:uuid/attendee/:attendee_uuid", getAttendee)
+	protected.POST("/list/:uuid/attendee", createAttendee)
+	protected.DELETE("/list/:uuid/attendee/:attendee_uuid", deleteAttendee)
+	protected.GET("/list/:uuid/mdreport", getMarkdownReport)
+}
 
-		listEntry, entryPresent := lists[listUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+// Middleware that marks a response as coming from a deprecated endpoint
+// and points to its successor in /v1
+func deprecatedRoute() gin.HandlerFunc {
+	apiPath := cfg.Server.BasePath + cfg.Server.APIPrefix
 
-		// This endpoint may only access lists that are unlisted or public
-		if listEntry.Visibility == 0 {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+	return func(context *gin.Context) {
+		successor := apiPath + "/v1" + strings.TrimPrefix(context.Request.URL.Path, apiPath)
+		context.Header("Deprecation", "true")
+		context.Header("Link", "<"+successor+">; rel=\"successor-version\"")
 
-		context.JSON(http.StatusOK, listEntry)
-	})
+		context.Next()
+	}
+}
 
-	// Retrieve a specific talking list
-	// In contrast to the public endpoint, this will also retrieve
-	// private lists
-	protected.GET("/list/:uuid", func(context *gin.Context) {
-		listUuid, err := uuid.Parse(context.Param("uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
+// Middleware that hides private talking lists from the public endpoints.
+// Every public route below /list/:uuid must be registered with it.
+func publicListAccess() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		_, listEntry, ok := lookupList(context)
+		if !ok {
 			return
 		}
 
-		listEntry, entryPresent := lists[listUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
+		// Private lists are reported as missing, so their existence is not revealed
+		if !listEntry.Visibility.accessible() {
+			abortWithError(context, http.StatusNotFound, errorCodeNotFound, "The talking list does not exist")
 			return
 		}
 
-		context.JSON(http.StatusOK, listEntry)
-	})
+		context.Next()
+	}
+}
 
-	// Create a new talking list
-	protected.POST("/list", func(context *gin.Context) {
-		listUuid := uuid.New()
+// Parse the UUID in the path parameter with the given name.
+// If it is invalid, the request is aborted.
+func parseUuidParam(context *gin.Context, name string) (uuid.UUID, bool) {
+	value, err := uuid.Parse(context.Param(name))
+	if err != nil {
+		abortWithError(context, http.StatusBadRequest, errorCodeInvalidParameter, "A parameter is invalid",
+			APIFieldError{Field: name, Message: "is not a valid UUID"})
+		return uuid.Nil, false
+	}
+
+	return value, true
+}
 
-		var requestData TalkingList
-		if err := context.ShouldBindJSON(&requestData); err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+// Look up the talking list referenced by the path parameter "uuid".
+// If it does not exist, the request is aborted.
+func lookupList(context *gin.Context) (uuid.UUID, TalkingList, bool) {
+	listUuid, ok := parseUuidParam(context, "uuid")
+	if !ok {
+		return uuid.Nil, TalkingList{}, false
+	}
+
+	listEntry, entryPresent := lists[listUuid]
+	if !entryPresent {
+		abortWithError(context, http.StatusNotFound, errorCodeNotFound, "The talking list does not exist")
+		return uuid.Nil, TalkingList{}, false
+	}
+
+	return listUuid, listEntry, true
+}
 
-		groupUuid := uuid.New()
-		var groupData TalkingListGroup
-		groupData.Name = "Redner"
-		requestData.Groups = make(map[uuid.UUID]TalkingListGroup)
-		requestData.Groups[groupUuid] = groupData
+// Look up the group of a talking list referenced by the path parameter "group_uuid".
+// If it does not exist, the request is aborted.
+func lookupGroup(context *gin.Context, listEntry TalkingList) (uuid.UUID, TalkingListGroup, bool) {
+	groupUuid, ok := parseUuidParam(context, "group_uuid")
+	if !ok {
+		return uuid.Nil, TalkingListGroup{}, false
+	}
+
+	groupEntry, entryPresent := listEntry.Groups[groupUuid]
+	if !entryPresent {
+		abortWithError(context, http.StatusNotFound, errorCodeNotFound, "The group does not exist")
+		return uuid.Nil, TalkingListGroup{}, false
+	}
+
+	return groupUuid, groupEntry, true
+}
 
-		lists[listUuid] = requestData
+// Look up the attendee of a talking list referenced by the path parameter "attendee_uuid".
+// If it does not exist, the request is aborted.
+func lookupAttendee(context *gin.Context, listEntry TalkingList) (uuid.UUID, TalkingListAttendee, bool) {
+	attendeeUuid, ok := parseUuidParam(context, "attendee_uuid")
+	if !ok {
+		return uuid.Nil, TalkingListAttendee{}, false
+	}
+
+	attendeeEntry, entryPresent := listEntry.Attendees[attendeeUuid]
+	if !entryPresent {
+		abortWithError(context, http.StatusNotFound, errorCodeNotFound, "The attendee does not exist")
+		return uuid.Nil, TalkingListAttendee{}, false
+	}
+
+	return attendeeUuid, attendeeEntry, true
+}
 
-		dumpListToFile()
+// Look up the agenda item of a talking list referenced by the path parameter "agenda_item_uuid"
+// and return its index. If it does not exist, the request is aborted.
+func lookupAgendaItem(context *gin.Context, listEntry TalkingList) (int, bool) {
+	itemUuid, ok := parseUuidParam(context, "agenda_item_uuid")
+	if !ok {
+		return -1, false
+	}
+
+	index := listEntry.agendaIndex(&itemUuid)
+	if index < 0 {
+		abortWithError(context, http.StatusNotFound, errorCodeNotFound, "The agenda item does not exist")
+		return -1, false
+	}
+
+	return index, true
+}
 
-		context.Status(http.StatusCreated)
-	})
+// Look up the motion of a talking list referenced by the path parameter "motion_uuid".
+// If it does not exist, the request is aborted.
+func lookupMotion(context *gin.Context, listEntry TalkingList) (uuid.UUID, TalkingListMotion, bool) {
+	motionUuid, ok := parseUuidParam(context, "motion_uuid")
+	if !ok {
+		return uuid.Nil, TalkingListMotion{}, false
+	}
+
+	motionEntry, entryPresent := listEntry.Motions[motionUuid]
+	if !entryPresent {
+		abortWithError(context, http.StatusNotFound, errorCodeNotFound, "The motion does not exist")
+		return uuid.Nil, TalkingListMotion{}, false
+	}
+
+	return motionUuid, motionEntry, true
+}
 
-	// Update the visibility of a talking list
-	protected.POST("/list/:uuid/visibility", func(context *gin.Context) {
-		listUuid, err := uuid.Parse(context.Param("uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+// Look up the past contribution of a talking list referenced by the path parameter "contribution_uuid"
+// and return its index. If it does not exist, the request is aborted.
+func lookupPastContribution(context *gin.Context, listEntry TalkingList) (int, bool) {
+	contributionUuid, ok := parseUuidParam(context, "contribution_uuid")
+	if !ok {
+		return -1, false
+	}
+
+	index := listEntry.pastContributionIndex(contributionUuid)
+	if index < 0 {
+		abortWithError(context, http.StatusNotFound, errorCodeNotFound, "The contribution does not exist")
+		return -1, false
+	}
+
+	return index, true
+}
 
-		listEntry, entryPresent := lists[listUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+// Respond to a request that created a resource.
+// The Location header points to the new resource, which is also returned along with its UUID.
+func respondCreated(context *gin.Context, resourceUuid uuid.UUID, resource interface{}) {
+	// Flatten the resource into an object that also contains the UUID
+	var body map[string]interface{}
+	resourceJson, err := json.Marshal(resource)
+	if err == nil {
+		err = json.Unmarshal(resourceJson, &body)
+	}
+	if err != nil {
+		abortWithError(context, http.StatusInternalServerError, errorCodeInternal, err.Error())
+		return
+	}
+	body["uuid"] = resourceUuid
+
+	context.Header("Location", strings.TrimSuffix(context.Request.URL.Path, "/")+"/"+resourceUuid.String())
+	context.JSON(http.StatusCreated, body)
+}
 
-		var requestData TalkingListVisibilityUpdate
-		if err := context.ShouldBindJSON(&requestData); err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
+// Retrieve all talking lists currently known to the application
+func getPublicLists(context *gin.Context) {
+	listsFiltered := make(map[uuid.UUID]TalkingList)
+
+	// Filter lists so only public lists are returned
+	for key, list := range lists {
+		if list.Visibility == VisibilityPublic {
+			listsFiltered[key] = list
 		}
+	}
 
-		listEntry.Visibility = requestData.NewVisibility
-		lists[listUuid] = listEntry
-		dumpListToFile()
+	context.JSON(http.StatusOK, listsFiltered)
+}
 
-		context.Status(http.StatusOK)
-	})
+// Retrieve all talking lists currently known to the application
+// In contrast to the public endpoint, this will also retrieve
+// private lists
+func getLists(context *gin.Context) {
+	context.JSON(http.StatusOK, lists)
+}
 
-	// Delete a talking list
-	protected.DELETE("/list/:uuid", func(context *gin.Context) {
-		listUuid, err := uuid.Parse(context.Param("uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+// Retrieve a specific talking list
+// The public endpoint only reaches unlisted and public lists, see publicListAccess
+func getList(context *gin.Context) {
+	_, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
 
-		_, entryPresent := lists[listUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+	context.JSON(http.StatusOK, listEntry.response(time.Now()))
+}
 
-		delete(lists, listUuid)
-		dumpListToFile()
+// Create a new talking list
+func createList(context *gin.Context) {
+	listUuid := uuid.New()
+
+	var requestData TalkingList
+	if err := context.ShouldBindJSON(&requestData); err != nil {
+		abortWithBindError(context, err)
+		return
+	}
+
+	groupUuid := uuid.New()
+	var groupData TalkingListGroup
+	groupData.Name = "Redner"
+	requestData.Groups = make(map[uuid.UUID]TalkingListGroup)
+	requestData.Groups[groupUuid] = groupData
+
+	// Revisions are counted by the server
+	requestData.Revision = 0
+
+	// Contributions are only recorded by the server, so each of them has a UUID
+	requestData.CurrentContribution = TalkingListContribution{}
+	requestData.PastContributions = nil
+
+	// The groups taking turns can only be chosen once they exist,
+	// so alternating between groups can not be set on creation
+	requestData.QueuePolicy.Groups = nil
+	if fields := requestData.validateQueuePolicy(requestData.QueuePolicy); len(fields) > 0 {
+		for index := range fields {
+			fields[index].Field = "queue_policy." + fields[index].Field
+		}
+		abortWithError(context, http.StatusBadRequest, errorCodeInvalidBody, "The queue policy is invalid", fields...)
+		return
+	}
+
+	// The agenda may be given, but it is not discussed yet
+	for index := range requestData.Agenda.Items {
+		requestData.Agenda.Items[index].Uuid = uuid.New()
+	}
+	requestData.Agenda.Current = nil
+
+	// Motions are created and voted on afterwards
+	requestData.Motions = nil
+	storeList(listUuid, &requestData)
+	logListAction(context, listUuid, "create_list", "name", requestData.Name)
+
+	respondCreated(context, listUuid, requestData)
+}
 
-		context.Status(http.StatusOK)
-	})
+// Update the visibility of a talking list
+func updateVisibility(context *gin.Context) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	var requestData TalkingListVisibilityUpdate
+	if err := context.ShouldBindJSON(&requestData); err != nil {
+		abortWithBindError(context, err)
+		return
+	}
+
+	previousVisibility := listEntry.Visibility
+	listEntry.Visibility = *requestData.NewVisibility
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "update_visibility", "visibility", listEntry.Visibility.String())
+	publishListEvent(listUuid, eventVisibilityChanged, VisibilityEvent{Visibility: listEntry.Visibility})
+	auditListAction(context, listUuid, auditActionUpdateVisibility,
+		"from", previousVisibility.String(), "to", listEntry.Visibility.String())
+
+	context.Status(http.StatusOK)
+}
 
-	// Retrieve all groups in a specific talking list
-	public.GET("/list/:uuid/group", func(context *gin.Context) {
-		listUuid, err := uuid.Parse(context.Param("uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+// Update the speaking time limits of a talking list.
+// They apply to contributions started afterwards.
+func updateSpeakingTime(context *gin.Context) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	var requestData TalkingListSpeakingTime
+	if err := context.ShouldBindJSON(&requestData); err != nil {
+		abortWithBindError(context, err)
+		return
+	}
+
+	listEntry.SpeakingTime = requestData
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "update_speaking_time", "limit", requestData.Limit,
+		"warning_threshold", requestData.WarningThreshold, "auto_stop", requestData.AutoStop)
+	publishListEvent(listUuid, eventSpeakingTimeChanged, SpeakingTimeEvent{SpeakingTime: &requestData})
+
+	context.JSON(http.StatusOK, listEntry.SpeakingTime)
+}
 
-		listEntry, entryPresent := lists[listUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+// Update the policy determining who is called next
+func updateQueuePolicy(context *gin.Context) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	var requestData TalkingListQueuePolicy
+	if err := context.ShouldBindJSON(&requestData); err != nil {
+		abortWithBindError(context, err)
+		return
+	}
+
+	if fields := listEntry.validateQueuePolicy(requestData); len(fields) > 0 {
+		abortWithError(context, http.StatusBadRequest, errorCodeInvalidBody, "The queue policy is invalid", fields...)
+		return
+	}
+
+	listEntry.QueuePolicy = requestData
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "update_queue_policy", "policy", requestData.Policy, "groups", requestData.Groups)
+	publishListEvent(listUuid, eventQueuePolicyChanged, requestData)
+
+	context.JSON(http.StatusOK, listEntry.QueuePolicy)
+}
 
-		context.JSON(http.StatusOK, listEntry.Groups)
-	})
+// Delete a talking list
+func deleteList(context *gin.Context) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
 
-	// Retrieve a single group in a specific talking list
-	public.GET("/list/:uuid/group/:group_uuid", func(context *gin.Context) {
-		listUuid, err := uuid.Parse(context.Param("uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+	removeList(listUuid)
+	logListAction(context, listUuid, "delete_list")
+	publishListEvent(listUuid, eventListDeleted, struct{}{})
+	auditListAction(context, listUuid, auditActionDeleteList, "name", listEntry.Name)
 
-		listEntry, entryPresent := lists[listUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+	context.Status(http.StatusOK)
+}
 
-		groupUuid, err := uuid.Parse(context.Param("group_uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+// Retrieve the agenda of a talking list
+func getAgenda(context *gin.Context) {
+	_, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
 
-		groupEntry, entryPresent := listEntry.Groups[groupUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+	context.JSON(http.StatusOK, listEntry.Agenda)
+}
 
-		context.JSON(http.StatusOK, groupEntry)
-	})
+// Retrieve a single item of the agenda of a talking list
+func getAgendaItem(context *gin.Context) {
+	_, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
 
-	// Create a group in a specific talking list
-	protected.POST("/list/:uuid/group", func(context *gin.Context) {
-		listUuid, err := uuid.Parse(context.Param("uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+	index, ok := lookupAgendaItem(context, listEntry)
+	if !ok {
+		return
+	}
 
-		listEntry, entryPresent := lists[listUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+	context.JSON(http.StatusOK, listEntry.Agenda.Items[index])
+}
 
-		var requestData TalkingListGroup
-		if err := context.ShouldBindJSON(&requestData); err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+// Add an item to the end of the agenda
+func createAgendaItem(context *gin.Context) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	var requestData TalkingListAgendaItem
+	if err := context.ShouldBindJSON(&requestData); err != nil {
+		abortWithBindError(context, err)
+		return
+	}
+
+	requestData.Uuid = uuid.New()
+	listEntry.Agenda.Items = append(listEntry.Agenda.Items, requestData)
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "create_agenda_item", "agenda_item", requestData.Uuid)
+	publishListEvent(listUuid, eventAgendaChanged, listEntry.Agenda)
+
+	respondCreated(context, requestData.Uuid, requestData)
+}
 
-		if listEntry.Groups == nil {
-			listEntry.Groups = make(map[uuid.UUID]TalkingListGroup)
-		}
+// Change the title of an agenda item and respond with it
+func updateAgendaItem(context *gin.Context) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	index, ok := lookupAgendaItem(context, listEntry)
+	if !ok {
+		return
+	}
+
+	var requestData TalkingListAgendaItem
+	if err := context.ShouldBindJSON(&requestData); err != nil {
+		abortWithBindError(context, err)
+		return
+	}
+
+	listEntry.Agenda.Items[index].Title = requestData.Title
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "update_agenda_item", "agenda_item", listEntry.Agenda.Items[index].Uuid)
+	publishListEvent(listUuid, eventAgendaChanged, listEntry.Agenda)
+
+	context.JSON(http.StatusOK, listEntry.Agenda.Items[index])
+}
 
-		groupUuid := uuid.New()
-		listEntry.Groups[groupUuid] = requestData
-		lists[listUuid] = listEntry
-		dumpListToFile()
+// Delete an agenda item. Items that contributions or motions belong to can not be deleted.
+func deleteAgendaItem(context *gin.Context) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	index, ok := lookupAgendaItem(context, listEntry)
+	if !ok {
+		return
+	}
+
+	itemUuid := listEntry.Agenda.Items[index].Uuid
+	if listEntry.agendaItemInUse(itemUuid) {
+		abortWithError(context, http.StatusConflict, errorCodeConflict, "Contributions or motions belong to the agenda item")
+		return
+	}
+
+	listEntry.Agenda.Items = append(listEntry.Agenda.Items[:index:index], listEntry.Agenda.Items[index+1:]...)
+	if sameAgendaItem(listEntry.Agenda.Current, &itemUuid) {
+		listEntry.Agenda.Current = nil
+	}
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "delete_agenda_item", "agenda_item", itemUuid)
+	publishListEvent(listUuid, eventAgendaChanged, listEntry.Agenda)
+
+	context.Status(http.StatusOK)
+}
 
-		context.Status(http.StatusCreated)
-	})
+// Move an agenda item to the position given in the request body and respond with the agenda
+func moveAgendaItem(context *gin.Context) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	index, ok := lookupAgendaItem(context, listEntry)
+	if !ok {
+		return
+	}
+
+	var requestData TalkingListAgendaItemMove
+	if err := context.ShouldBindJSON(&requestData); err != nil {
+		abortWithBindError(context, err)
+		return
+	}
+
+	item := listEntry.Agenda.Items[index]
+	items := append(listEntry.Agenda.Items[:index:index], listEntry.Agenda.Items[index+1:]...)
+	position := min(*requestData.Position, len(items)+1)
+	listEntry.Agenda.Items = append(items[:position-1:position-1], append([]TalkingListAgendaItem{item}, items[position-1:]...)...)
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "move_agenda_item", "agenda_item", item.Uuid, "position", position)
+	publishListEvent(listUuid, eventAgendaChanged, listEntry.Agenda)
+
+	context.JSON(http.StatusOK, listEntry.Agenda)
+}
 
-	// Delete a group from a specific talking list
-	protected.DELETE("/list/:uuid/group/:group_uuid", func(context *gin.Context) {
-		listUuid, err := uuid.Parse(context.Param("uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+// Make an agenda item the one currently discussed and respond with the agenda.
+// Contributions started afterwards belong to it.
+func setCurrentAgendaItem(context *gin.Context) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	index, ok := lookupAgendaItem(context, listEntry)
+	if !ok {
+		return
+	}
+
+	current := listEntry.Agenda.Items[index].Uuid
+	listEntry.Agenda.Current = &current
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "set_current_agenda_item", "agenda_item", current)
+	publishListEvent(listUuid, eventAgendaChanged, listEntry.Agenda)
+
+	context.JSON(http.StatusOK, listEntry.Agenda)
+}
 
-		listEntry, entryPresent := lists[listUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+// Move on to the next agenda item and respond with the agenda
+func nextAgendaItem(context *gin.Context) {
+	listUuid, _, ok := lookupList(context)
+	if !ok {
+		return
+	}
 
-		groupUuid, err := uuid.Parse(context.Param("group_uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+	agenda, err := advanceAgenda(context, listUuid)
+	if err != nil {
+		abortWithActionError(context, err)
+		return
+	}
 
-		_, entryPresent = listEntry.Groups[groupUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+	context.JSON(http.StatusOK, agenda)
+}
 
-		delete(listEntry.Groups, groupUuid)
-		lists[listUuid] = listEntry
-		dumpListToFile()
+// Retrieve all motions of a talking list
+func getMotions(context *gin.Context) {
+	_, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
 
-		context.Status(http.StatusOK)
-	})
+	context.JSON(http.StatusOK, listEntry.Motions)
+}
 
-	// Get the time distribution between groups in a specific talking list
-	public.GET("/list/:uuid/time_distribution", func(context *gin.Context) {
-		listUuid, err := uuid.Parse(context.Param("uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+// Retrieve a single motion of a talking list
+func getMotion(context *gin.Context) {
+	_, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
 
-		listEntry, entryPresent := lists[listUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+	_, motionEntry, ok := lookupMotion(context, listEntry)
+	if !ok {
+		return
+	}
 
-		groupTimeShare := make(map[uuid.UUID]time.Duration)
-		groupNumberContributions := make(map[uuid.UUID]uint)
-		totalTime := time.Duration(0)
-		for uuid := range listEntry.Groups {
-			groupTimeShare[uuid] = 0
-		}
-		for _, contribution := range listEntry.PastContributions {
-			groupTimeShare[contribution.GroupUuid] += contribution.Duration
-			groupNumberContributions[contribution.GroupUuid]++
-			totalTime += contribution.Duration
-		}
+	context.JSON(http.StatusOK, motionEntry)
+}
 
-		context.JSON(http.StatusOK, gin.H{
-			"time_share":           groupTimeShare,
-			"total_time":           totalTime,
-			"number_contributions": groupNumberContributions,
-		})
-	})
-
-	// Reset the list of previous contributions in a specific talking list
-	protected.GET("/list/:uuid/reset_past_contributions", func(context *gin.Context) {
-		listUuid, err := uuid.Parse(context.Param("uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+// Create a motion, it belongs to the current agenda item
+func createMotion(context *gin.Context) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	var requestData TalkingListMotion
+	if err := context.ShouldBindJSON(&requestData); err != nil {
+		abortWithBindError(context, err)
+		return
+	}
+
+	if listEntry.Motions == nil {
+		listEntry.Motions = make(map[uuid.UUID]TalkingListMotion)
+	}
+
+	motionUuid := uuid.New()
+	motion := TalkingListMotion{
+		Text:           requestData.Text,
+		Mover:          requestData.Mover,
+		AgendaItemUuid: listEntry.currentAgendaItem(),
+		Status:         MotionStatusDraft,
+		CreatedAt:      time.Now(),
+	}
+	listEntry.Motions[motionUuid] = motion
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "create_motion", "motion", motionUuid)
+	publishListEvent(listUuid, eventMotionCreated, MotionEvent{MotionUuid: motionUuid, Motion: &motion})
+
+	respondCreated(context, motionUuid, motion)
+}
 
-		listEntry, entryPresent := lists[listUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+// Open voting on a motion and respond with it
+func openMotion(context *gin.Context) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	motionUuid, motionEntry, ok := lookupMotion(context, listEntry)
+	if !ok {
+		return
+	}
+
+	if motionEntry.Status != MotionStatusDraft {
+		abortWithError(context, http.StatusConflict, errorCodeConflict, "Voting on the motion has already been opened")
+		return
+	}
+
+	motionEntry.Status = MotionStatusOpen
+	motionEntry.OpenedAt = time.Now()
+	listEntry.Motions[motionUuid] = motionEntry
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "open_motion", "motion", motionUuid)
+	publishListEvent(listUuid, eventMotionOpened, MotionEvent{MotionUuid: motionUuid, Motion: &motionEntry})
+
+	context.JSON(http.StatusOK, motionEntry)
+}
 
-		listEntry.PastContributions = make([]TalkingListContribution, 0)
-		lists[listUuid] = listEntry
-		dumpListToFile()
+// Close voting on a motion, record the votes counted by the moderator and respond with the motion
+func closeMotion(context *gin.Context) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	motionUuid, motionEntry, ok := lookupMotion(context, listEntry)
+	if !ok {
+		return
+	}
+
+	var requestData TalkingListMotionClose
+	if err := context.ShouldBindJSON(&requestData); err != nil {
+		abortWithBindError(context, err)
+		return
+	}
+
+	if motionEntry.Status != MotionStatusOpen {
+		abortWithError(context, http.StatusConflict, errorCodeConflict, "Voting on the motion is not open")
+		return
+	}
+
+	votes := TalkingListVotes{Yes: *requestData.Yes, No: *requestData.No, Abstain: *requestData.Abstain}
+	motionEntry.Status = MotionStatusClosed
+	motionEntry.ClosedAt = time.Now()
+	motionEntry.Votes = &votes
+	motionEntry.Result = votes.result()
+	listEntry.Motions[motionUuid] = motionEntry
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "close_motion", "motion", motionUuid, "result", motionEntry.Result)
+	publishListEvent(listUuid, eventMotionClosed, MotionEvent{MotionUuid: motionUuid, Motion: &motionEntry})
+	auditListAction(context, listUuid, auditActionCloseMotion,
+		"motion", motionUuid, "yes", votes.Yes, "no", votes.No, "abstain", votes.Abstain, "result", motionEntry.Result)
+
+	context.JSON(http.StatusOK, motionEntry)
+}
 
-		context.Status(http.StatusOK)
-	})
+// Delete a motion, including its recorded votes
+func deleteMotion(context *gin.Context) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	motionUuid, motionEntry, ok := lookupMotion(context, listEntry)
+	if !ok {
+		return
+	}
+
+	delete(listEntry.Motions, motionUuid)
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "delete_motion", "motion", motionUuid)
+	publishListEvent(listUuid, eventMotionDeleted, MotionEvent{MotionUuid: motionUuid})
+	auditListAction(context, listUuid, auditActionDeleteMotion,
+		"motion", motionUuid, "text", motionEntry.Text, "status", motionEntry.Status, "result", motionEntry.Result)
+
+	context.Status(http.StatusOK)
+}
 
-	// Get the list of applications in a specific talking group
-	public.GET("/list/:uuid/group/:group_uuid/application", func(context *gin.Context) {
-		listUuid, err := uuid.Parse(context.Param("uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+// Retrieve all groups in a specific talking list
+func getGroups(context *gin.Context) {
+	_, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
 
-		listEntry, entryPresent := lists[listUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+	context.JSON(http.StatusOK, listEntry.Groups)
+}
 
-		groupUuid, err := uuid.Parse(context.Param("group_uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+// Retrieve a single group in a specific talking list
+func getGroup(context *gin.Context) {
+	_, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
 
-		groupEntry, entryPresent := listEntry.Groups[groupUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+	_, groupEntry, ok := lookupGroup(context, listEntry)
+	if !ok {
+		return
+	}
 
-		context.JSON(http.StatusOK, groupEntry.Applications)
-	})
+	context.JSON(http.StatusOK, groupEntry)
+}
 
-	// Add an application in a specific talking group
-	public.POST("/list/:uuid/group/:group_uuid/application", func(context *gin.Context) {
-		listUuid, err := uuid.Parse(context.Param("uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+// Create a group in a specific talking list
+func createGroup(context *gin.Context) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	var requestData TalkingListGroup
+	if err := context.ShouldBindJSON(&requestData); err != nil {
+		abortWithBindError(context, err)
+		return
+	}
+
+	if listEntry.Groups == nil {
+		listEntry.Groups = make(map[uuid.UUID]TalkingListGroup)
+	}
+
+	groupUuid := uuid.New()
+	listEntry.Groups[groupUuid] = requestData
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "create_group", "group", groupUuid)
+	publishListEvent(listUuid, eventGroupCreated, GroupEvent{GroupUuid: groupUuid, Group: &requestData})
+
+	respondCreated(context, groupUuid, requestData)
+}
 
-		listEntry, entryPresent := lists[listUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+// Update the speaking time limit of a group.
+// It applies to contributions started afterwards.
+func updateGroupSpeakingTime(context *gin.Context) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	groupUuid, groupEntry, ok := lookupGroup(context, listEntry)
+	if !ok {
+		return
+	}
+
+	var requestData TalkingListGroupSpeakingTimeUpdate
+	if err := context.ShouldBindJSON(&requestData); err != nil {
+		abortWithBindError(context, err)
+		return
+	}
+
+	groupEntry.SpeakingTimeLimit = *requestData.SpeakingTimeLimit
+	listEntry.Groups[groupUuid] = groupEntry
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "update_group_speaking_time", "group", groupUuid, "limit", groupEntry.SpeakingTimeLimit)
+	publishListEvent(listUuid, eventSpeakingTimeChanged, SpeakingTimeEvent{GroupUuid: &groupUuid, SpeakingTimeLimit: requestData.SpeakingTimeLimit})
+
+	context.JSON(http.StatusOK, groupEntry)
+}
 
-		groupUuid, err := uuid.Parse(context.Param("group_uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+// Delete a group from a specific talking list
+func deleteGroup(context *gin.Context) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	groupUuid, groupEntry, ok := lookupGroup(context, listEntry)
+	if !ok {
+		return
+	}
+
+	delete(listEntry.Groups, groupUuid)
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "delete_group", "group", groupUuid)
+	publishListEvent(listUuid, eventGroupDeleted, GroupEvent{GroupUuid: groupUuid})
+	auditListAction(context, listUuid, auditActionDeleteGroup, "group", groupUuid, "name", groupEntry.Name)
+
+	context.Status(http.StatusOK)
+}
 
-		groupEntry, entryPresent := listEntry.Groups[groupUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+// Get the time distribution between groups in a specific talking list
+func getTimeDistribution(context *gin.Context) {
+	_, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
 
-		var requestData TalkingListApplication
-		if err := context.ShouldBindJSON(&requestData); err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+	context.JSON(http.StatusOK, listEntry.timeDistribution())
+}
 
-		if groupEntry.Applications == nil {
-			groupEntry.Applications = make(map[uuid.UUID]TalkingListApplication)
-		}
+// Get the order in which the applications of all groups will be called
+func getSpeakingOrder(context *gin.Context) {
+	_, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
 
-		applicationUuid := uuid.New()
-		groupEntry.Applications[applicationUuid] = requestData
-		listEntry.Groups[groupUuid] = groupEntry
-		lists[listUuid] = listEntry
-		dumpListToFile()
-
-		context.JSON(http.StatusCreated, gin.H{
-			"uuid": applicationUuid,
-		})
-	})
-
-	// Delete an application from a talking group
-	public.DELETE("/list/:uuid/group/:group_uuid/application/:application_uuid", func(context *gin.Context) {
-		listUuid, err := uuid.Parse(context.Param("uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+	context.JSON(http.StatusOK, listEntry.speakingOrder())
+}
 
-		listEntry, entryPresent := lists[listUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+// Get the timer of the current contribution in a specific talking list
+func getTimer(context *gin.Context) {
+	_, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
 
-		groupUuid, err := uuid.Parse(context.Param("group_uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+	context.JSON(http.StatusOK, listEntry.timer(time.Now()))
+}
 
-		groupEntry, entryPresent := listEntry.Groups[groupUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+// Reset the list of previous contributions in a specific talking list
+func resetPastContributions(context *gin.Context) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	numberContributions := len(listEntry.PastContributions)
+	listEntry.PastContributions = make([]TalkingListContribution, 0)
+	listEntry.undo = nil
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "reset_past_contributions")
+	publishListEvent(listUuid, eventPastContributionsReset, struct{}{})
+	auditListAction(context, listUuid, auditActionResetPastContributions, "contributions", numberContributions)
+
+	context.Status(http.StatusOK)
+}
 
-		applicationUuid, err := uuid.Parse(context.Param("application_uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+// Correct the times, the speaker or the group of a past contribution and respond with it.
+// The previous and the corrected contribution are recorded in the audit log.
+func updateContribution(context *gin.Context) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	index, ok := lookupPastContribution(context, listEntry)
+	if !ok {
+		return
+	}
+
+	var requestData TalkingListContributionUpdate
+	if err := context.ShouldBindJSON(&requestData); err != nil {
+		abortWithBindError(context, err)
+		return
+	}
+
+	previousContribution := listEntry.PastContributions[index]
+	start, end := previousContribution.StartTime, previousContribution.EndTime
+	if requestData.StartTime != nil {
+		start = *requestData.StartTime
+	}
+	if requestData.EndTime != nil {
+		end = *requestData.EndTime
+	}
+
+	var fields []APIFieldError
+	if !end.After(start) {
+		fields = append(fields, APIFieldError{Field: "end_time", Message: "must be after start_time"})
+	}
+	if requestData.GroupUuid != nil {
+		if _, entryPresent := listEntry.Groups[*requestData.GroupUuid]; !entryPresent {
+			fields = append(fields, APIFieldError{Field: "group_uuid", Message: "refers to an unknown group"})
+		}
+	}
+	if requestData.Name != nil && strings.TrimSpace(*requestData.Name) == "" {
+		fields = append(fields, APIFieldError{Field: "name", Message: "must not be empty"})
+	}
+	if requestData.AttendeeUuid != nil {
+		if _, entryPresent := listEntry.Attendees[*requestData.AttendeeUuid]; !entryPresent {
+			fields = append(fields, APIFieldError{Field: "attendee_uuid", Message: "refers to an unknown attendee"})
+		}
+	}
+	if requestData.AgendaItemUuid != nil && listEntry.agendaIndex(requestData.AgendaItemUuid) < 0 {
+		fields = append(fields, APIFieldError{Field: "agenda_item_uuid", Message: "refers to an unknown agenda item"})
+	}
+	if len(fields) > 0 {
+		abortWithError(context, http.StatusBadRequest, errorCodeInvalidBody, "The request body is invalid", fields...)
+		return
+	}
+
+	contribution := previousContribution
+	contribution.correctTimes(start, end)
+	if requestData.GroupUuid != nil {
+		contribution.GroupUuid = *requestData.GroupUuid
+	}
+	if requestData.Name != nil {
+		contribution.Application.Name = *requestData.Name
+	}
+	if requestData.AttendeeUuid != nil {
+		contribution.Application.AttendeeUuid = requestData.AttendeeUuid
+	}
+	if requestData.AgendaItemUuid != nil {
+		contribution.AgendaItemUuid = requestData.AgendaItemUuid
+	}
+
+	listEntry.PastContributions[index] = contribution
+	listEntry.undo = nil
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "edit_contribution", "contribution", contribution.Uuid)
+	publishListEvent(listUuid, eventContributionUpdated, contribution)
+	auditListAction(context, listUuid, auditActionEditContribution,
+		"contribution", contribution.Uuid, "before", previousContribution, "after", contribution)
+
+	context.JSON(http.StatusOK, contribution)
+}
 
-		delete(groupEntry.Applications, applicationUuid)
-		listEntry.Groups[groupUuid] = groupEntry
-		lists[listUuid] = listEntry
-		dumpListToFile()
+// Delete a past contribution, e.g. one that was started by mistake.
+// The deleted contribution is recorded in the audit log.
+func deleteContribution(context *gin.Context) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	index, ok := lookupPastContribution(context, listEntry)
+	if !ok {
+		return
+	}
+
+	contribution := listEntry.PastContributions[index]
+	listEntry.PastContributions = append(listEntry.PastContributions[:index:index], listEntry.PastContributions[index+1:]...)
+	listEntry.undo = nil
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "delete_contribution", "contribution", contribution.Uuid)
+	publishListEvent(listUuid, eventContributionDeleted, ContributionEvent{ContributionUuid: contribution.Uuid})
+	auditListAction(context, listUuid, auditActionDeleteContribution,
+		"contribution", contribution.Uuid, "deleted", contribution)
+
+	context.Status(http.StatusOK)
+}
 
-		context.Status(http.StatusOK)
-	})
+// Get the list of applications in a specific talking group
+func getApplications(context *gin.Context) {
+	_, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
 
-	// Start the contribution (from an application)
-	protected.GET("/list/:uuid/start_contribution", func(context *gin.Context) {
-		listUuid, err := uuid.Parse(context.Param("uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+	groupUuid, _, ok := lookupGroup(context, listEntry)
+	if !ok {
+		return
+	}
 
-		listEntry, entryPresent := lists[listUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+	context.JSON(http.StatusOK, listEntry.queuedApplications(groupUuid))
+}
 
-		groupUuid, err := uuid.Parse(context.Query("group"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+// Retrieve all applications in a specific talking group by their UUID, as the deprecated endpoints did
+func getApplicationMap(context *gin.Context) {
+	_, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
 
-		groupEntry, entryPresent := listEntry.Groups[groupUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+	_, groupEntry, ok := lookupGroup(context, listEntry)
+	if !ok {
+		return
+	}
 
-		applicationUuid, err := uuid.Parse(context.Query("application"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+	context.JSON(http.StatusOK, groupEntry.Applications)
+}
 
-		applicationEntry, entryPresent := groupEntry.Applications[applicationUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+// Get a single application in a specific talking group
+func getApplication(context *gin.Context) {
+	_, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	_, groupEntry, ok := lookupGroup(context, listEntry)
+	if !ok {
+		return
+	}
+
+	applicationUuid, ok := parseUuidParam(context, "application_uuid")
+	if !ok {
+		return
+	}
+
+	applicationEntry, entryPresent := groupEntry.Applications[applicationUuid]
+	if !entryPresent {
+		abortWithError(context, http.StatusNotFound, errorCodeNotFound, "The application does not exist")
+		return
+	}
+
+	context.JSON(http.StatusOK, applicationEntry)
+}
 
-		now := time.Now()
+// Add an application in a specific talking group
+func createApplication(context *gin.Context) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	groupUuid, groupEntry, ok := lookupGroup(context, listEntry)
+	if !ok {
+		return
+	}
+
+	var requestData TalkingListApplication
+	if err := context.ShouldBindJSON(&requestData); err != nil {
+		abortWithBindError(context, err)
+		return
+	}
+
+	if requestData.AttendeeUuid != nil {
+		if _, entryPresent := listEntry.Attendees[*requestData.AttendeeUuid]; !entryPresent {
+			abortWithError(context, http.StatusBadRequest, errorCodeInvalidBody, "The request body is invalid",
+				APIFieldError{Field: "attendee_uuid", Message: "refers to an unknown attendee"})
+			return
+		}
+	}
+
+	if err := listEntry.checkApplicationLimit(requestData); err != nil {
+		abortWithActionError(context, err)
+		return
+	}
+
+	if groupEntry.Applications == nil {
+		groupEntry.Applications = make(map[uuid.UUID]TalkingListApplication)
+	}
+
+	// New applications are queued at the end of their group
+	requestData.SubmittedAt = time.Now()
+	requestData.Position = len(groupEntry.Applications) + 1
+
+	applicationUuid := uuid.New()
+	groupEntry.Applications[applicationUuid] = requestData
+	groupEntry.setQueue(groupEntry.queue())
+	requestData = groupEntry.Applications[applicationUuid]
+	listEntry.Groups[groupUuid] = groupEntry
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "create_application", "group", groupUuid, "application", applicationUuid)
+	publishListEvent(listUuid, eventApplicationCreated,
+		ApplicationEvent{GroupUuid: groupUuid, ApplicationUuid: applicationUuid, Application: &requestData})
+
+	respondCreated(context, applicationUuid, requestData)
+}
 
-		if listEntry.CurrentContribution.InProgress {
-			prevContribution := listEntry.CurrentContribution
-			prevContribution.EndTime = now
-			prevContribution.Duration = prevContribution.EndTime.Sub(prevContribution.StartTime)
-			prevContribution.InProgress = false
-			listEntry.PastContributions = append(listEntry.PastContributions, prevContribution)
-		}
+// Delete an application from a talking group
+func deleteApplication(context *gin.Context) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	groupUuid, _, ok := lookupGroup(context, listEntry)
+	if !ok {
+		return
+	}
+
+	applicationUuid, ok := parseUuidParam(context, "application_uuid")
+	if !ok {
+		return
+	}
+
+	if err := withdrawApplication(context, listUuid, groupUuid, applicationUuid); err != nil {
+		abortWithActionError(context, err)
+		return
+	}
+
+	context.Status(http.StatusOK)
+}
 
-		listEntry.CurrentContribution.StartTime = now
-		listEntry.CurrentContribution.Application = applicationEntry
-		listEntry.CurrentContribution.GroupUuid = groupUuid
-		listEntry.CurrentContribution.InProgress = true
-
-		delete(groupEntry.Applications, applicationUuid)
-		listEntry.Groups[groupUuid] = groupEntry
-		lists[listUuid] = listEntry
-		dumpListToFile()
-	})
-
-	// Stop the current application
-	protected.GET("/list/:uuid/stop_contribution", func(context *gin.Context) {
-		listUuid, err := uuid.Parse(context.Param("uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+// Look up the list, group and application a request to move an application refers to
+func lookupApplicationMove(context *gin.Context) (listUuid uuid.UUID, groupUuid uuid.UUID, applicationUuid uuid.UUID, ok bool) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
 
-		listEntry, entryPresent := lists[listUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+	groupUuid, _, ok = lookupGroup(context, listEntry)
+	if !ok {
+		return
+	}
 
-		if listEntry.CurrentContribution.InProgress {
-			prevContribution := listEntry.CurrentContribution
-			prevContribution.EndTime = time.Now()
-			prevContribution.Duration = prevContribution.EndTime.Sub(prevContribution.StartTime)
-			prevContribution.InProgress = false
-			listEntry.PastContributions = append(listEntry.PastContributions, prevContribution)
-		}
+	applicationUuid, ok = parseUuidParam(context, "application_uuid")
+	return
+}
 
-		listEntry.CurrentContribution.InProgress = false
-		lists[listUuid] = listEntry
-		dumpListToFile()
-	})
+// Move an application to the position given in the request body and respond with the new queue
+func repositionApplication(context *gin.Context) {
+	listUuid, groupUuid, applicationUuid, ok := lookupApplicationMove(context)
+	if !ok {
+		return
+	}
 
-	// Retrieve all attendees in a specific talking list
-	protected.GET("/list/:uuid/attendee", func(context *gin.Context) {
-		listUuid, err := uuid.Parse(context.Param("uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+	var requestData TalkingListApplicationMove
+	if err := context.ShouldBindJSON(&requestData); err != nil {
+		abortWithBindError(context, err)
+		return
+	}
 
-		listEntry, entryPresent := lists[listUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+	respondMovedApplication(context, listUuid, groupUuid, applicationUuid, *requestData.Position)
+}
 
-		context.JSON(http.StatusOK, listEntry.Attendees)
-	})
+// Move an application to the front of the queue of its group
+func moveApplicationToTop(context *gin.Context) {
+	listUuid, groupUuid, applicationUuid, ok := lookupApplicationMove(context)
+	if !ok {
+		return
+	}
 
-	// Retrieve a single attendee in a specific talking list
-	protected.GET("/list/:uuid/attendee/:attendee_uuid", func(context *gin.Context) {
-		listUuid, err := uuid.Parse(context.Param("uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+	respondMovedApplication(context, listUuid, groupUuid, applicationUuid, 1)
+}
 
-		listEntry, entryPresent := lists[listUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+// Move an application to the end of the queue of its group
+func moveApplicationToBottom(context *gin.Context) {
+	listUuid, groupUuid, applicationUuid, ok := lookupApplicationMove(context)
+	if !ok {
+		return
+	}
 
-		attendeeUuid, err := uuid.Parse(context.Param("attendee_uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+	respondMovedApplication(context, listUuid, groupUuid, applicationUuid, math.MaxInt)
+}
 
-		attendeeEntry, entryPresent := listEntry.Attendees[attendeeUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+// Move an application and respond with the new queue of its group
+func respondMovedApplication(context *gin.Context, listUuid uuid.UUID, groupUuid uuid.UUID, applicationUuid uuid.UUID, position int) {
+	queue, err := moveApplication(context, listUuid, groupUuid, applicationUuid, position)
+	if err != nil {
+		abortWithActionError(context, err)
+		return
+	}
 
-		context.JSON(http.StatusOK, attendeeEntry)
-	})
+	context.JSON(http.StatusOK, queue)
+}
 
-	// Create an attendee in a specific talking list
-	protected.POST("/list/:uuid/attendee", func(context *gin.Context) {
-		listUuid, err := uuid.Parse(context.Param("uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+// Start the contribution (from an application given in the request body)
+func startContribution(context *gin.Context) {
+	var requestData TalkingListContributionStart
+	if err := context.ShouldBindJSON(&requestData); err != nil {
+		abortWithBindError(context, err)
+		return
+	}
 
-		listEntry, entryPresent := lists[listUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+	startContributionFromApplication(context, requestData.GroupUuid, requestData.ApplicationUuid)
+}
 
-		var requestData TalkingListAttendee
-		if err := context.ShouldBindJSON(&requestData); err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+// Start the contribution (from an application given in the query parameters "group" and "application")
+func startContributionLegacy(context *gin.Context) {
+	groupUuid, err := uuid.Parse(context.Query("group"))
+	if err != nil {
+		abortWithError(context, http.StatusBadRequest, errorCodeInvalidParameter, "A parameter is invalid",
+			APIFieldError{Field: "group", Message: "is not a valid UUID"})
+		return
+	}
+
+	applicationUuid, err := uuid.Parse(context.Query("application"))
+	if err != nil {
+		abortWithError(context, http.StatusBadRequest, errorCodeInvalidParameter, "A parameter is invalid",
+			APIFieldError{Field: "application", Message: "is not a valid UUID"})
+		return
+	}
+
+	startContributionFromApplication(context, groupUuid, applicationUuid)
+}
 
-		if listEntry.Attendees == nil {
-			listEntry.Attendees = make(map[uuid.UUID]TalkingListAttendee)
-		}
+// Start the contribution of an application, a running contribution is stopped before
+func startContributionFromApplication(context *gin.Context, groupUuid uuid.UUID, applicationUuid uuid.UUID) {
+	listUuid, _, ok := lookupList(context)
+	if !ok {
+		return
+	}
 
-		attendeeUuid := uuid.New()
-		listEntry.Attendees[attendeeUuid] = requestData
-		lists[listUuid] = listEntry
-		dumpListToFile()
+	contribution, err := startApplicationContribution(context, listUuid, groupUuid, applicationUuid)
+	if err != nil {
+		abortWithActionError(context, err)
+		return
+	}
 
-		context.Status(http.StatusCreated)
-	})
+	context.JSON(http.StatusOK, contribution)
+}
 
-	// Delete an attendee from a specific talking list
-	protected.DELETE("/list/:uuid/attendee/:attendee_uuid", func(context *gin.Context) {
-		listUuid, err := uuid.Parse(context.Param("uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+// Stop the current application
+func stopContribution(context *gin.Context) {
+	listUuid, _, ok := lookupList(context)
+	if !ok {
+		return
+	}
 
-		listEntry, entryPresent := lists[listUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+	if err := stopCurrentContribution(context, listUuid); err != nil {
+		abortWithActionError(context, err)
+		return
+	}
 
-		attendeeUuid, err := uuid.Parse(context.Param("attendee_uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+	context.Status(http.StatusOK)
+}
 
-		_, entryPresent = listEntry.Attendees[attendeeUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+// Pause the running contribution and respond with it
+func pauseContribution(context *gin.Context) {
+	listUuid, _, ok := lookupList(context)
+	if !ok {
+		return
+	}
 
-		delete(listEntry.Attendees, attendeeUuid)
-		lists[listUuid] = listEntry
-		dumpListToFile()
+	contribution, err := pauseCurrentContribution(context, listUuid)
+	if err != nil {
+		abortWithActionError(context, err)
+		return
+	}
 
-		context.Status(http.StatusOK)
-	})
+	context.JSON(http.StatusOK, contribution)
+}
 
-	// Get a Markdown report of an event that may be converted to user-readable PDF format using pandoc
-	protected.GET("/list/:uuid/mdreport", func(context *gin.Context) {
-		listUuid, err := uuid.Parse(context.Param("uuid"))
-		if err != nil {
-			context.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
+// Resume the paused contribution and respond with it
+func resumeContribution(context *gin.Context) {
+	listUuid, _, ok := lookupList(context)
+	if !ok {
+		return
+	}
 
-		listEntry, entryPresent := lists[listUuid]
-		if !entryPresent {
-			context.AbortWithStatus(http.StatusNotFound)
-			return
-		}
+	contribution, err := resumeCurrentContribution(context, listUuid)
+	if err != nil {
+		abortWithActionError(context, err)
+		return
+	}
 
-		// Calculate the time distribution
-		groupTimeShare := make(map[uuid.UUID]time.Duration)
-		groupNumberContributions := make(map[uuid.UUID]uint)
-		totalTime := time.Duration(0)
-		for uuid := range listEntry.Groups {
-			groupTimeShare[uuid] = 0
-		}
-		for _, contribution := range listEntry.PastContributions {
-			groupTimeShare[contribution.GroupUuid] += contribution.Duration
-			groupNumberContributions[contribution.GroupUuid]++
-			totalTime += contribution.Duration
-		}
+	context.JSON(http.StatusOK, contribution)
+}
 
-		// Combine all time distribution data into a structure for use in the template
-		type GroupTimeDistribution struct {
-			GroupName         string
-			NumContributions  uint
-			TimeShareAbsolute time.Duration
-			TimeShareRelative float64
-		}
-		groupTimeDistributions := make(map[uuid.UUID]GroupTimeDistribution)
-		for uuid := range listEntry.Groups {
-			var groupTimeDistribution GroupTimeDistribution
+// End the running contribution and call the next speaker according to the queue policy.
+// Responds with the started contribution and the speaker following it.
+func nextContribution(context *gin.Context) {
+	listUuid, _, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	next, err := startNextContribution(context, listUuid)
+	if err != nil {
+		abortWithActionError(context, err)
+		return
+	}
+
+	context.JSON(http.StatusOK, next)
+}
 
-			groupTimeDistribution.GroupName = listEntry.Groups[uuid].Name
-			groupTimeDistribution.NumContributions = groupNumberContributions[uuid]
-			groupTimeDistribution.TimeShareAbsolute = groupTimeShare[uuid]
-			groupTimeDistribution.TimeShareRelative = math.Floor(((groupTimeShare[uuid].Seconds()/totalTime.Seconds())*100)*100) / 100
+// Undo the last start or stop of a contribution and respond with the current contribution
+func undoContribution(context *gin.Context) {
+	listUuid, _, ok := lookupList(context)
+	if !ok {
+		return
+	}
 
-			groupTimeDistributions[uuid] = groupTimeDistribution
-		}
+	contribution, err := undoContributionAction(context, listUuid)
+	if err != nil {
+		abortWithActionError(context, err)
+		return
+	}
 
-		context.Header("Content-Type", "text/markdown")
-
-		// Fill template with data and write to HTTP stream
-		reportTemplate := template.Must(template.New("report.got").Funcs(template.FuncMap{
-			"prettyDuration": func(duration time.Duration) string {
-				return durafmt.Parse(duration).LimitFirstN(1).String()
-			},
-			"getGroupName": func(uuid uuid.UUID) string {
-				groupEntry, entryPresent := listEntry.Groups[uuid]
-				if !entryPresent {
-					return ""
-				}
-				return groupEntry.Name
-			},
-			"timeDistribution": func(uuid uuid.UUID) GroupTimeDistribution {
-				return groupTimeDistributions[uuid]
-			},
-			"timeNow": time.Now,
-		}).ParseFiles("report.got"))
-
-		err = reportTemplate.Execute(context.Writer, listEntry)
-		if err != nil {
-			context.AbortWithStatus(http.StatusInternalServerError)
-			return
-		}
+	context.JSON(http.StatusOK, contribution)
+}
+
+// Retrieve all attendees in a specific talking list
+func getAttendees(context *gin.Context) {
+	_, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	context.JSON(http.StatusOK, listEntry.Attendees)
+}
+
+// Retrieve a single attendee in a specific talking list
+func getAttendee(context *gin.Context) {
+	_, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	_, attendeeEntry, ok := lookupAttendee(context, listEntry)
+	if !ok {
+		return
+	}
+
+	context.JSON(http.StatusOK, attendeeEntry)
+}
+
+// Create an attendee in a specific talking list
+func createAttendee(context *gin.Context) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	var requestData TalkingListAttendee
+	if err := context.ShouldBindJSON(&requestData); err != nil {
+		abortWithBindError(context, err)
+		return
+	}
+
+	if listEntry.Attendees == nil {
+		listEntry.Attendees = make(map[uuid.UUID]TalkingListAttendee)
+	}
+
+	attendeeUuid := uuid.New()
+	listEntry.Attendees[attendeeUuid] = requestData
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "create_attendee", "attendee", attendeeUuid)
+
+	respondCreated(context, attendeeUuid, requestData)
+}
+
+// Delete an attendee from a specific talking list
+func deleteAttendee(context *gin.Context) {
+	listUuid, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	attendeeUuid, attendeeEntry, ok := lookupAttendee(context, listEntry)
+	if !ok {
+		return
+	}
+
+	delete(listEntry.Attendees, attendeeUuid)
+	storeList(listUuid, &listEntry)
+	logListAction(context, listUuid, "delete_attendee", "attendee", attendeeUuid)
+	auditListAction(context, listUuid, auditActionDeleteAttendee,
+		"attendee", attendeeUuid, "name", attendeeEntry.GivenName+" "+attendeeEntry.SurName)
+
+	context.Status(http.StatusOK)
+}
 
-		context.Status(http.StatusOK)
-	})
+// Get a Markdown report of an event that may be converted to user-readable PDF format using pandoc
+func getMarkdownReport(context *gin.Context) {
+	_, listEntry, ok := lookupList(context)
+	if !ok {
+		return
+	}
+
+	// The report is only sent if it was generated completely
+	var report bytes.Buffer
+	if err := renderReport(&report, listEntry); err != nil {
+		abortWithError(context, http.StatusInternalServerError, errorCodeInternal, "Generating the report failed")
+		return
+	}
+
+	context.Data(http.StatusOK, "text/markdown", report.Bytes())
 }
@@ -0,0 +1,382 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Send a request to the router and return the recorded response
+func doRequest(router *gin.Engine, method string, path string, body string, token string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// Log in as the administrator of users.json.example and return the token
+func login(t *testing.T, router *gin.Engine) string {
+	t.Helper()
+
+	response := doRequest(router, http.MethodPost, "/v1/login", `{"username": "admin", "password": "admin"}`, "")
+	if response.Code != http.StatusOK {
+		t.Fatalf("Login failed with status %d: %s", response.Code, response.Body.String())
+	}
+
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Decoding login response failed: %v", err)
+	}
+	return body.Token
+}
+
+// Create a resource with a POST request and return the UUID from the response
+func createResource(t *testing.T, router *gin.Engine, path string, body string, token string) uuid.UUID {
+	t.Helper()
+
+	response := doRequest(router, http.MethodPost, path, body, token)
+	if response.Code != http.StatusCreated {
+		t.Fatalf("POST %s returned status %d, expected %d: %s", path, response.Code, http.StatusCreated, response.Body.String())
+	}
+
+	var created struct {
+		Uuid uuid.UUID `json:"uuid"`
+	}
+	if err := json.Unmarshal(response.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Decoding response of POST %s failed: %v", path, err)
+	}
+	if location := response.Header().Get("Location"); location != path+"/"+created.Uuid.String() {
+		t.Errorf("POST %s returned Location %q for the resource %s", path, location, created.Uuid)
+	}
+	return created.Uuid
+}
+
+func TestErrorsAreReportedAsJSON(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		token  string
+		status int
+		code   string
+		field  string
+	}{
+		{http.MethodGet, "/v1/public/list/invalid", "", "", http.StatusBadRequest, errorCodeInvalidParameter, "uuid"},
+		{http.MethodGet, "/v1/public/list/" + uuid.NewString(), "", "", http.StatusNotFound, errorCodeNotFound, ""},
+		{http.MethodGet, "/v1/protected/list", "", "", http.StatusUnauthorized, errorCodeUnauthorized, ""},
+		{http.MethodPost, "/v1/protected/list", `{}`, token, http.StatusBadRequest, errorCodeInvalidBody, "name"},
+		{http.MethodPost, "/v1/protected/list", `{"name": `, token, http.StatusBadRequest, errorCodeInvalidBody, ""},
+	}
+
+	for _, test := range tests {
+		response := doRequest(router, test.method, test.path, test.body, test.token)
+		if response.Code != test.status {
+			t.Errorf("%s %s returned status %d, expected %d", test.method, test.path, response.Code, test.status)
+		}
+
+		var body APIErrorResponse
+		if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+			t.Errorf("%s %s returned no JSON error body: %v", test.method, test.path, err)
+			continue
+		}
+		if body.Error.Code != test.code || body.Error.Message == "" {
+			t.Errorf("%s %s returned the error %+v, expected code %q", test.method, test.path, body.Error, test.code)
+		}
+		if test.field != "" && (len(body.Error.Fields) != 1 || body.Error.Fields[0].Field != test.field) {
+			t.Errorf("%s %s reported the fields %+v, expected %q", test.method, test.path, body.Error.Fields, test.field)
+		}
+	}
+}
+
+func TestCreatedResourcesHaveLocation(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+
+	listUuid := createResource(t, router, "/v1/protected/list", `{"name": "Test", "visibility": 1}`, token)
+	listPath := "/v1/protected/list/" + listUuid.String()
+	groupUuid := createResource(t, router, listPath+"/group", `{"name": "Gäste"}`, token)
+	groupPath := "/v1/public/list/" + listUuid.String() + "/group/" + groupUuid.String()
+	applicationUuid := createResource(t, router, groupPath+"/application", `{"name": "Alice"}`, "")
+
+	for _, path := range []string{listPath, listPath + "/group/" + groupUuid.String(), groupPath + "/application/" + applicationUuid.String()} {
+		if response := doRequest(router, http.MethodGet, path, "", token); response.Code != http.StatusOK {
+			t.Errorf("GET %s of a created resource returned status %d, expected %d", path, response.Code, http.StatusOK)
+		}
+	}
+}
+
+func TestStateChangesUsePost(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+
+	listUuid := createResource(t, router, "/v1/protected/list", `{"name": "Test", "visibility": 1}`, token)
+	listPath := "/v1/protected/list/" + listUuid.String()
+	groupUuid := createResource(t, router, listPath+"/group", `{"name": "Gäste"}`, token)
+	applicationUuid := createResource(t, router, "/v1/public/list/"+listUuid.String()+"/group/"+groupUuid.String()+"/application", `{"name": "Alice"}`, "")
+
+	for _, action := range []string{"start_contribution", "stop_contribution", "reset_past_contributions"} {
+		if response := doRequest(router, http.MethodGet, listPath+"/"+action, "", token); response.Code != http.StatusNotFound {
+			t.Errorf("GET %s returned status %d, expected %d", action, response.Code, http.StatusNotFound)
+		}
+	}
+
+	body := `{"group_uuid": "` + groupUuid.String() + `", "application_uuid": "` + applicationUuid.String() + `"}`
+	response := doRequest(router, http.MethodPost, listPath+"/start_contribution", body, token)
+	if response.Code != http.StatusOK || !lists[listUuid].CurrentContribution.InProgress {
+		t.Fatalf("Starting the contribution returned status %d: %s", response.Code, response.Body.String())
+	}
+
+	response = doRequest(router, http.MethodPost, listPath+"/stop_contribution", "", token)
+	if response.Code != http.StatusOK || lists[listUuid].CurrentContribution.InProgress || len(lists[listUuid].PastContributions) != 1 {
+		t.Errorf("Stopping the contribution returned status %d, past contributions are %v", response.Code, lists[listUuid].PastContributions)
+	}
+}
+
+func TestLegacyRoutesAreDeprecated(t *testing.T) {
+	router := setupTestRouter(t)
+
+	response := doRequest(router, http.MethodGet, "/public/list", "", "")
+	if response.Code != http.StatusOK {
+		t.Errorf("GET /public/list returned status %d, expected %d", response.Code, http.StatusOK)
+	}
+	if response.Header().Get("Deprecation") != "true" || response.Header().Get("Link") != `</v1/public/list>; rel="successor-version"` {
+		t.Errorf("GET /public/list returned the headers %v, expected a deprecation notice", response.Header())
+	}
+
+	response = doRequest(router, http.MethodGet, "/v1/public/list", "", "")
+	if response.Header().Get("Deprecation") != "" {
+		t.Errorf("GET /v1/public/list is marked as deprecated")
+	}
+}
+
+// Add a talking list with a single group and application to the database
+func addTestList(visibility TalkingListVisibility) (listUuid, groupUuid, applicationUuid uuid.UUID) {
+	listUuid, groupUuid, applicationUuid = uuid.New(), uuid.New(), uuid.New()
+	lists[listUuid] = TalkingList{
+		Name:       "Test",
+		Visibility: visibility,
+		Groups: map[uuid.UUID]TalkingListGroup{
+			groupUuid: {
+				Name: "Redner",
+				Applications: map[uuid.UUID]TalkingListApplication{
+					applicationUuid: {Name: "Alice"},
+				},
+			},
+		},
+	}
+	return
+}
+
+// Collect all registered public routes that refer to a single talking list,
+// with their path parameters replaced by the given UUIDs
+func publicListRoutes(router *gin.Engine, listUuid, groupUuid, applicationUuid uuid.UUID) []gin.RouteInfo {
+	replacer := strings.NewReplacer(
+		":uuid", listUuid.String(),
+		":group_uuid", groupUuid.String(),
+		":application_uuid", applicationUuid.String(),
+	)
+
+	var routes []gin.RouteInfo
+	for _, route := range router.Routes() {
+		if strings.Contains(route.Path, "/public/list/:uuid") {
+			route.Path = replacer.Replace(route.Path)
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+func TestPublicRoutesHidePrivateLists(t *testing.T) {
+	router := setupTestRouter(t)
+	listUuid, groupUuid, applicationUuid := addTestList(VisibilityPrivate)
+
+	routes := publicListRoutes(router, listUuid, groupUuid, applicationUuid)
+	if len(routes) == 0 {
+		t.Fatal("No public routes below /public/list/:uuid are registered")
+	}
+
+	for _, route := range routes {
+		response := doRequest(router, route.Method, route.Path, `{"name": "Mallory"}`, "")
+		if response.Code != http.StatusNotFound {
+			t.Errorf("%s %s on a private list returned status %d, expected %d",
+				route.Method, route.Path, response.Code, http.StatusNotFound)
+		}
+	}
+
+	applications := lists[listUuid].Groups[groupUuid].Applications
+	if _, present := applications[applicationUuid]; !present || len(applications) != 1 {
+		t.Errorf("The applications of a private list were modified through public routes: %v", applications)
+	}
+}
+
+func TestPublicRoutesServeUnlistedLists(t *testing.T) {
+	router := setupTestRouter(t)
+	listUuid, groupUuid, applicationUuid := addTestList(VisibilityUnlisted)
+
+	motionUuid := uuid.New()
+	listEntry := lists[listUuid]
+	listEntry.Motions = map[uuid.UUID]TalkingListMotion{motionUuid: {Text: "Antrag", Mover: "Alice", Status: MotionStatusDraft}}
+	agendaItemUuid := uuid.New()
+	listEntry.Agenda.Items = []TalkingListAgendaItem{{Uuid: agendaItemUuid, Title: "Haushalt"}}
+	lists[listUuid] = listEntry
+
+	for _, route := range publicListRoutes(router, listUuid, groupUuid, applicationUuid) {
+		// Event streams do not end on their own, they are tested in events_test.go
+		if route.Method != http.MethodGet || strings.HasSuffix(route.Path, "/events") {
+			continue
+		}
+
+		route.Path = strings.ReplaceAll(route.Path, ":motion_uuid", motionUuid.String())
+		route.Path = strings.ReplaceAll(route.Path, ":agenda_item_uuid", agendaItemUuid.String())
+		response := doRequest(router, route.Method, route.Path, "", "")
+		if response.Code != http.StatusOK {
+			t.Errorf("%s %s on an unlisted list returned status %d, expected %d",
+				route.Method, route.Path, response.Code, http.StatusOK)
+		}
+	}
+}
+
+func TestPublicListsOnlyContainPublicLists(t *testing.T) {
+	router := setupTestRouter(t)
+	addTestList(VisibilityPrivate)
+	addTestList(VisibilityUnlisted)
+	publicUuid, _, _ := addTestList(VisibilityPublic)
+
+	response := doRequest(router, http.MethodGet, "/v1/public/list", "", "")
+	var body map[uuid.UUID]TalkingList
+	if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Decoding response failed: %v", err)
+	}
+
+	if _, present := body[publicUuid]; !present || len(body) != 1 {
+		t.Errorf("Expected only the public list %s, got %v", publicUuid, body)
+	}
+}
+
+func TestVisibilityIsValidated(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+	listUuid, _, _ := addTestList(VisibilityPublic)
+	path := "/v1/protected/list/" + listUuid.String() + "/visibility"
+
+	for _, body := range []string{`{"new_visibility": 3}`, `{"new_visibility": -1}`, `{}`, `{"new_visibility": "public"}`} {
+		response := doRequest(router, http.MethodPost, path, body, token)
+		if response.Code != http.StatusBadRequest {
+			t.Errorf("Updating the visibility with %s returned status %d, expected %d", body, response.Code, http.StatusBadRequest)
+		}
+	}
+	if lists[listUuid].Visibility != VisibilityPublic {
+		t.Errorf("An invalid request changed the visibility to %v", lists[listUuid].Visibility)
+	}
+
+	response := doRequest(router, http.MethodPost, path, `{"new_visibility": 0}`, token)
+	if response.Code != http.StatusOK || lists[listUuid].Visibility != VisibilityPrivate {
+		t.Errorf("Making the list private returned status %d, visibility is %v", response.Code, lists[listUuid].Visibility)
+	}
+
+	response = doRequest(router, http.MethodPost, "/v1/protected/list", `{"name": "Test", "visibility": 7}`, token)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Creating a list with an invalid visibility returned status %d, expected %d", response.Code, http.StatusBadRequest)
+	}
+}
+
+func TestTimerIsCalculatedByServer(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+	listUuid, groupUuid, applicationUuid := addTestList(VisibilityPublic)
+	listPath := "/v1/protected/list/" + listUuid.String()
+
+	var timer TalkingListTimer
+	response := doRequest(router, http.MethodGet, "/v1/public/list/"+listUuid.String()+"/timer", "", "")
+	if err := json.Unmarshal(response.Body.Bytes(), &timer); err != nil || timer.Running || timer.Elapsed != 0 {
+		t.Errorf("The timer without a contribution is %s", response.Body.String())
+	}
+
+	start := `{"group_uuid": "` + groupUuid.String() + `", "application_uuid": "` + applicationUuid.String() + `"}`
+	doRequest(router, http.MethodPost, listPath+"/start_contribution", start, token)
+	time.Sleep(10 * time.Millisecond)
+
+	var list TalkingListResponse
+	response = doRequest(router, http.MethodGet, listPath, "", token)
+	if err := json.Unmarshal(response.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Decoding the list failed: %v", err)
+	}
+
+	timer = list.Timer
+	if !timer.Running || timer.Elapsed < 10*time.Millisecond || timer.Revision != list.Revision || timer.Remaining != nil {
+		t.Errorf("The timer of a running contribution is %+v", timer)
+	}
+	if timer.ServerTime.Sub(timer.StartTime) != timer.Elapsed || time.Since(timer.ServerTime) > time.Second {
+		t.Errorf("The elapsed time %v does not match server time %v and start time %v", timer.Elapsed, timer.ServerTime, timer.StartTime)
+	}
+}
+
+func TestCreateListIgnoresContributions(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+
+	body := `{
+		"name": "Test",
+		"current_contribution": {"in_progress": true, "application": {"name": "Mallory"}},
+		"past_contributions": [{"application": {"name": "Mallory"}, "duration": 60000000000}]
+	}`
+	listUuid := createResource(t, router, "/v1/protected/list", body, token)
+
+	listEntry := lists[listUuid]
+	if listEntry.CurrentContribution.InProgress || len(listEntry.PastContributions) != 0 {
+		t.Errorf("Contributions given on creation were stored: %+v, %+v", listEntry.CurrentContribution, listEntry.PastContributions)
+	}
+}
+
+func TestReportWithoutContributions(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+	listUuid, _, _ := addTestList(VisibilityPublic)
+	listPath := "/v1/protected/list/" + listUuid.String()
+	addTestAgenda(t, router, token, listUuid, "Begrüßung", "Finanzen")
+
+	response := doRequest(router, http.MethodGet, listPath+"/mdreport", "", token)
+	if response.Code != http.StatusOK || response.Header().Get("Content-Type") != "text/markdown" {
+		t.Fatalf("The report returned status %d with type %q", response.Code, response.Header().Get("Content-Type"))
+	}
+	if report := response.Body.String(); !strings.Contains(report, "Finanzen") || strings.Contains(report, "NaN") {
+		t.Errorf("The report of a list without contributions is invalid:\n%s", report)
+	}
+}
@@ -0,0 +1,214 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Prefix of the listen address that denotes a unix socket
+const unixSocketPrefix = "unix:"
+
+// Minimum interval between two checks whether the TLS certificate changed on disk
+const certificateCheckInterval = 10 * time.Second
+
+// certificateReloader provides the TLS certificate to the HTTP server and
+// reloads it from disk once the certificate or key file has been modified.
+type certificateReloader struct {
+	// The path to the PEM encoded certificate (chain)
+	certPath string
+
+	// The path to the PEM encoded private key
+	keyPath string
+
+	// Protects all fields below
+	mutex sync.Mutex
+
+	// The currently loaded certificate
+	certificate *tls.Certificate
+
+	// Modification times of the certificate and key file when they were loaded
+	certModTime time.Time
+	keyModTime  time.Time
+
+	// The time of the last check for modifications
+	lastCheck time.Time
+}
+
+// Create a certificateReloader and load the certificate initially
+func newCertificateReloader(certPath string, keyPath string) (*certificateReloader, error) {
+	reloader := &certificateReloader{
+		certPath: certPath,
+		keyPath:  keyPath,
+	}
+
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+// Load the certificate and key from disk, if they were modified since the last load.
+// The caller must hold the mutex, unless the reloader has not been shared yet.
+func (reloader *certificateReloader) reload() error {
+	certInfo, err := os.Stat(reloader.certPath)
+	if err != nil {
+		return err
+	}
+
+	keyInfo, err := os.Stat(reloader.keyPath)
+	if err != nil {
+		return err
+	}
+
+	if reloader.certificate != nil &&
+		certInfo.ModTime().Equal(reloader.certModTime) &&
+		keyInfo.ModTime().Equal(reloader.keyModTime) {
+		return nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(reloader.certPath, reloader.keyPath)
+	if err != nil {
+		return err
+	}
+
+	reloader.certificate = &certificate
+	reloader.certModTime = certInfo.ModTime()
+	reloader.keyModTime = keyInfo.ModTime()
+
+	return nil
+}
+
+// GetCertificate is used as callback in tls.Config.
+// When reloading fails (e.g. because only one of both files has been replaced yet),
+// the previously loaded certificate is used.
+func (reloader *certificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	reloader.mutex.Lock()
+	defer reloader.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(reloader.lastCheck) >= certificateCheckInterval {
+		reloader.lastCheck = now
+		if err := reloader.reload(); err != nil {
+			slog.Error("Failed to reload TLS certificate", "error", err)
+		}
+	}
+
+	return reloader.certificate, nil
+}
+
+// Open the listener for the HTTP server as configured.
+// This will either be a TCP or a unix socket.
+func serverListen() (net.Listener, error) {
+	if strings.HasPrefix(cfg.Server.Listen, unixSocketPrefix) {
+		socketPath := strings.TrimPrefix(cfg.Server.Listen, unixSocketPrefix)
+
+		// Remove a stale socket of a previous run
+		if err := os.Remove(socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		return net.Listen("unix", socketPath)
+	}
+
+	return net.Listen("tcp", cfg.Server.Listen)
+}
+
+// Serve HTTP requests using the given handler on the configured listener.
+// This function blocks until the server fails or SIGINT or SIGTERM is received.
+func serverRun(handler http.Handler) error {
+	signalContext, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	return serverServe(signalContext, handler)
+}
+
+// Serve HTTP requests using the given handler on the configured listener.
+// If a TLS certificate is configured, HTTPS will be used.
+// This function blocks until the server fails or stopContext is done.
+// In the latter case, no new connections are accepted and in-flight requests
+// are given the configured timeout to finish.
+func serverServe(stopContext context.Context, handler http.Handler) error {
+	server := &http.Server{
+		Handler: handler,
+	}
+
+	// Streams would otherwise keep the server from shutting down until the timeout
+	server.RegisterOnShutdown(closeListEvents)
+
+	useTLS := cfg.Server.TLS.CertPath != "" || cfg.Server.TLS.KeyPath != ""
+	if useTLS {
+		reloader, err := newCertificateReloader(cfg.Server.TLS.CertPath, cfg.Server.TLS.KeyPath)
+		if err != nil {
+			return err
+		}
+
+		server.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: reloader.GetCertificate,
+		}
+	}
+
+	listener, err := serverListen()
+	if err != nil {
+		return err
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		if useTLS {
+			// Certificate and key are provided by the TLS config
+			serverErr <- server.ServeTLS(listener, "", "")
+		} else {
+			serverErr <- server.Serve(listener)
+		}
+	}()
+
+	select {
+	case err := <-serverErr:
+		return err
+	case <-stopContext.Done():
+	}
+
+	slog.Info("Shutting down web server")
+
+	shutdownContext, cancel := context.WithTimeout(context.Background(), time.Duration(*cfg.Server.ShutdownTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownContext); err != nil {
+		slog.Warn("Not all requests finished in time", "error", err)
+	}
+
+	return nil
+}
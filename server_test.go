@@ -0,0 +1,269 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Create a client sending all requests to a unix socket.
+// Connecting is retried until the server listens on the socket.
+func unixSocketClient(socketPath string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			for {
+				conn, err := dialer.DialContext(ctx, "unix", socketPath)
+				if err == nil || ctx.Err() != nil {
+					return conn, err
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		},
+	}}
+}
+
+// Reopen the event streams after the test, as shutting down the server closes them for good
+func reopenListEventsOnCleanup(t *testing.T) {
+	t.Cleanup(func() {
+		listEvents.mutex.Lock()
+		listEvents.closed = false
+		listEvents.mutex.Unlock()
+	})
+}
+
+func TestServerListensOnUnixSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "list-o-matic.sock")
+	router := setupTestRouter(t, func(c *Config) {
+		c.Server.Listen = unixSocketPrefix + socketPath
+		shutdownTimeout := 5
+		c.Server.ShutdownTimeoutSeconds = &shutdownTimeout
+	})
+	reopenListEventsOnCleanup(t)
+
+	// The socket of a previous run is replaced
+	if err := os.WriteFile(socketPath, nil, 0600); err != nil {
+		t.Fatalf("Creating stale socket failed: %v", err)
+	}
+
+	stopContext, stop := context.WithCancel(context.Background())
+	defer stop()
+	serverDone := make(chan error, 1)
+	go func() {
+		serverDone <- serverServe(stopContext, router)
+	}()
+
+	client := unixSocketClient(socketPath)
+	client.Timeout = 5 * time.Second
+	response, err := client.Get("http://list-o-matic/v1/public/list")
+	if err != nil {
+		t.Fatalf("Requesting through the unix socket failed: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Requesting through the unix socket returned status %d, expected %d", response.StatusCode, http.StatusOK)
+	}
+
+	stop()
+	select {
+	case err := <-serverDone:
+		if err != nil {
+			t.Errorf("Shutting down the server failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The server did not shut down")
+	}
+}
+
+func TestInFlightRequestsFinishOnShutdown(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "list-o-matic.sock")
+	cfg = Config{}
+	cfg.Server.Listen = unixSocketPrefix + socketPath
+	shutdownTimeout := 5
+	cfg.Server.ShutdownTimeoutSeconds = &shutdownTimeout
+	reopenListEventsOnCleanup(t)
+
+	requestStarted := make(chan struct{})
+	finishRequest := make(chan struct{})
+	handler := http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		close(requestStarted)
+		<-finishRequest
+		io.WriteString(writer, "finished")
+	})
+
+	stopContext, stop := context.WithCancel(context.Background())
+	defer stop()
+	serverDone := make(chan error, 1)
+	go func() {
+		serverDone <- serverServe(stopContext, handler)
+	}()
+
+	client := unixSocketClient(socketPath)
+
+	type result struct {
+		body string
+		err  error
+	}
+	responseDone := make(chan result, 1)
+	go func() {
+		response, err := client.Get("http://list-o-matic/")
+		if err != nil {
+			responseDone <- result{err: err}
+			return
+		}
+		defer response.Body.Close()
+		body, err := io.ReadAll(response.Body)
+		responseDone <- result{string(body), err}
+	}()
+
+	select {
+	case <-requestStarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The request did not reach the handler")
+	}
+
+	// The server must wait for the request, which is still in progress
+	stop()
+	select {
+	case err := <-serverDone:
+		t.Fatalf("The server shut down while a request was in flight: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(finishRequest)
+	select {
+	case response := <-responseDone:
+		if response.err != nil || response.body != "finished" {
+			t.Errorf("The in-flight request returned %q, error %v", response.body, response.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The in-flight request did not finish")
+	}
+
+	select {
+	case err := <-serverDone:
+		if err != nil {
+			t.Errorf("Shutting down the server failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The server did not shut down")
+	}
+}
+
+// Write a self-signed certificate for the given host name and its key to the given paths
+func writeTestCertificate(t *testing.T, certPath string, keyPath string, host string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Generating key failed: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDer, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Creating certificate failed: %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Marshalling key failed: %v", err)
+	}
+
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDer})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err := os.WriteFile(certPath, certPem, 0600); err != nil {
+		t.Fatalf("Writing certificate failed: %v", err)
+	}
+	if err := os.WriteFile(keyPath, keyPem, 0600); err != nil {
+		t.Fatalf("Writing key failed: %v", err)
+	}
+}
+
+// Return the common name of the certificate currently provided by the reloader
+func reloadedCommonName(t *testing.T, reloader *certificateReloader) string {
+	t.Helper()
+
+	// Pretend the last check was long ago, so the files are checked again
+	reloader.lastCheck = time.Time{}
+
+	certificate, err := reloader.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatalf("Getting certificate failed: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		t.Fatalf("Parsing certificate failed: %v", err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertificateIsReloaded(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeTestCertificate(t, certPath, keyPath, "old.example.org")
+
+	reloader, err := newCertificateReloader(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("Loading certificate failed: %v", err)
+	}
+	if name := reloadedCommonName(t, reloader); name != "old.example.org" {
+		t.Errorf("Expected the initial certificate, got %s", name)
+	}
+
+	// Make sure the modification times differ from the initial load
+	writeTestCertificate(t, certPath, keyPath, "new.example.org")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certPath, later, later)
+	os.Chtimes(keyPath, later, later)
+	if name := reloadedCommonName(t, reloader); name != "new.example.org" {
+		t.Errorf("Expected the replaced certificate, got %s", name)
+	}
+
+	// A broken key keeps the previous certificate in use
+	os.WriteFile(keyPath, []byte("broken"), 0600)
+	latest := later.Add(time.Minute)
+	os.Chtimes(keyPath, latest, latest)
+	if name := reloadedCommonName(t, reloader); name != "new.example.org" {
+		t.Errorf("Expected the previous certificate after a failed reload, got %s", name)
+	}
+}
@@ -0,0 +1,103 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Interval in which running contributions are checked against their speaking time limit
+const speakingTimeCheckInterval = time.Second
+
+// Check the running contributions of all lists against their speaking time limits
+// until the returned function is called
+func startSpeakingTimeChecks() (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+
+		ticker := time.NewTicker(speakingTimeCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				checkSpeakingTimes(now)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// Warn the speakers of all lists whose time is running out and
+// stop contributions which have reached their limit, if the list asks for it
+func checkSpeakingTimes(now time.Time) {
+	listsMutex.Lock()
+	defer listsMutex.Unlock()
+
+	for listUuid, listEntry := range lists {
+		contribution := listEntry.CurrentContribution
+		if !contribution.InProgress || contribution.Paused || contribution.TimeLimit == 0 {
+			continue
+		}
+
+		elapsed := contribution.speakingTime(now)
+		if listEntry.SpeakingTime.AutoStop && elapsed >= contribution.TimeLimit {
+			autoStopContribution(listUuid, listEntry, now)
+			continue
+		}
+
+		threshold := listEntry.SpeakingTime.WarningThreshold
+		if !contribution.warningPublished && threshold > 0 && contribution.TimeLimit-elapsed <= threshold {
+			// The warning does not change the stored list, so the revision stays the same
+			listEntry.CurrentContribution.warningPublished = true
+			lists[listUuid] = listEntry
+			publishListEvent(listUuid, eventContributionWarning, listEntry.timer(now))
+		}
+	}
+}
+
+// Stop the running contribution of a list because its time ran out.
+// It ends exactly at its limit, even if the check ran a little later.
+// listsMutex has to be held and the contribution must not be paused.
+func autoStopContribution(listUuid uuid.UUID, listEntry TalkingList, now time.Time) {
+	contribution := listEntry.CurrentContribution
+	listEntry.CurrentContribution.AutoStopped = true
+	end := contribution.StartTime.Add(contribution.TimeLimit + contribution.pausedTime(now))
+	listEntry.finishCurrentContribution(end)
+	storeList(listUuid, &listEntry)
+
+	slog.Info("List modified", "list", listUuid, "action", "auto_stop_contribution", "user", "server",
+		"group", contribution.GroupUuid, "limit", contribution.TimeLimit)
+	publishListEvent(listUuid, eventContributionStopped, listEntry.PastContributions[len(listEntry.PastContributions)-1])
+}
@@ -0,0 +1,197 @@
+//     __    _      __        ____        __  ___      __  _
+//    / /   (_)____/ /_      / __ \      /  |/  /___ _/ /_(_)____
+//   / /   / / ___/ __/_____/ / / /_____/ /|_/ / __ `/ __/ / ___/
+//  / /___/ (__  ) /_/_____/ /_/ /_____/ /  / / /_/ / /_/ / /__
+// /_____/_/____/\__/      \____/     /_/  /_/\__,_/\__/_/\___/
+//
+// Copyright 2021-2022 Jan Blaesi
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files
+// (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge,
+// publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO
+// THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF
+// CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER
+// DEALINGS IN THE SOFTWARE.
+
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSpeakingTimeLimitsAreValidated(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+	listUuid, groupUuid, _ := addTestList(VisibilityPublic)
+	listPath := "/v1/protected/list/" + listUuid.String()
+
+	response := doRequest(router, http.MethodPost, listPath+"/speaking_time", `{"limit": -1}`, token)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("A negative limit returned status %d, expected %d", response.Code, http.StatusBadRequest)
+	}
+
+	response = doRequest(router, http.MethodPost, listPath+"/group/"+groupUuid.String()+"/speaking_time", `{}`, token)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("A group limit without value returned status %d, expected %d", response.Code, http.StatusBadRequest)
+	}
+
+	response = doRequest(router, http.MethodPost, listPath+"/speaking_time", `{"limit": 180000000000, "warning_threshold": 30000000000}`, token)
+	if response.Code != http.StatusOK || lists[listUuid].SpeakingTime.Limit != 3*time.Minute {
+		t.Errorf("Setting the limit returned status %d, limits are %+v", response.Code, lists[listUuid].SpeakingTime)
+	}
+}
+
+func TestContributionsAreStoppedWhenTimeRunsOut(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+	listUuid, groupUuid, applicationUuid := addTestList(VisibilityPublic)
+	listPath := "/v1/protected/list/" + listUuid.String()
+
+	doRequest(router, http.MethodPost, listPath+"/speaking_time", `{"limit": 180000000000, "warning_threshold": 30000000000, "auto_stop": true}`, token)
+	doRequest(router, http.MethodPost, listPath+"/group/"+groupUuid.String()+"/speaking_time", `{"speaking_time_limit": 60000000000}`, token)
+
+	events, _, _, _ := subscribeListEvents(listUuid, nil)
+	defer unsubscribeListEvents(listUuid, events)
+
+	start := `{"group_uuid": "` + groupUuid.String() + `", "application_uuid": "` + applicationUuid.String() + `"}`
+	doRequest(router, http.MethodPost, listPath+"/start_contribution", start, token)
+	contribution := lists[listUuid].CurrentContribution
+	if contribution.TimeLimit != time.Minute {
+		t.Fatalf("The contribution has the limit %v, expected the limit of its group", contribution.TimeLimit)
+	}
+
+	checkSpeakingTimes(contribution.StartTime.Add(45 * time.Second))
+	checkSpeakingTimes(contribution.StartTime.Add(50 * time.Second))
+	checkSpeakingTimes(contribution.StartTime.Add(61 * time.Second))
+
+	var received []string
+	for len(events) > 0 {
+		received = append(received, (<-events).Type)
+	}
+	expected := []string{eventApplicationDeleted, eventContributionStarted, eventContributionWarning, eventContributionStopped}
+	if strings.Join(received, ",") != strings.Join(expected, ",") {
+		t.Errorf("Received the events %v, expected %v", received, expected)
+	}
+
+	listEntry := lists[listUuid]
+	if listEntry.CurrentContribution.InProgress || len(listEntry.PastContributions) != 1 {
+		t.Fatalf("The contribution was not stopped: %+v", listEntry)
+	}
+	past := listEntry.PastContributions[0]
+	if !past.AutoStopped || past.Overrun || past.Duration != time.Minute {
+		t.Errorf("The stopped contribution is %+v", past)
+	}
+}
+
+func TestOverrunsAreFlagged(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+	listUuid, groupUuid, applicationUuid := addTestList(VisibilityPublic)
+	listPath := "/v1/protected/list/" + listUuid.String()
+
+	doRequest(router, http.MethodPost, listPath+"/speaking_time", `{"limit": 1000000}`, token)
+	start := `{"group_uuid": "` + groupUuid.String() + `", "application_uuid": "` + applicationUuid.String() + `"}`
+	doRequest(router, http.MethodPost, listPath+"/start_contribution", start, token)
+	time.Sleep(5 * time.Millisecond)
+
+	// Without auto-stop, the contribution keeps running
+	checkSpeakingTimes(time.Now())
+	listEntry := lists[listUuid]
+	timer := listEntry.timer(time.Now())
+	if !listEntry.CurrentContribution.InProgress || !timer.Overrun || timer.Remaining == nil || *timer.Remaining >= 0 {
+		t.Fatalf("The timer of an overrunning contribution is %+v", timer)
+	}
+
+	doRequest(router, http.MethodPost, listPath+"/stop_contribution", "", token)
+	if past := lists[listUuid].PastContributions; len(past) != 1 || !past[0].Overrun {
+		t.Errorf("The overrun was not recorded: %+v", past)
+	}
+
+	response := doRequest(router, http.MethodGet, listPath+"/mdreport", "", token)
+	if !strings.Contains(response.Body.String(), "**überschritten**") || !strings.Contains(response.Body.String(), "Überschrittene Redezeiten: 1") {
+		t.Errorf("The report does not flag the overrun:\n%s", response.Body.String())
+	}
+}
+
+func TestPausesDoNotCountAsSpeakingTime(t *testing.T) {
+	router := setupTestRouter(t)
+	token := login(t, router)
+	listUuid, groupUuid, applicationUuid := addTestList(VisibilityPublic)
+	listPath := "/v1/protected/list/" + listUuid.String()
+
+	if response := doRequest(router, http.MethodPost, listPath+"/pause_contribution", "", token); response.Code != http.StatusConflict {
+		t.Errorf("Pausing without a contribution returned status %d, expected %d", response.Code, http.StatusConflict)
+	}
+
+	start := `{"group_uuid": "` + groupUuid.String() + `", "application_uuid": "` + applicationUuid.String() + `"}`
+	doRequest(router, http.MethodPost, listPath+"/start_contribution", start, token)
+	doRequest(router, http.MethodPost, listPath+"/pause_contribution", "", token)
+	if response := doRequest(router, http.MethodPost, listPath+"/pause_contribution", "", token); response.Code != http.StatusConflict {
+		t.Errorf("Pausing twice returned status %d, expected %d", response.Code, http.StatusConflict)
+	}
+
+	listEntry := lists[listUuid]
+	now := time.Now()
+	before, after := listEntry.timer(now), listEntry.timer(now.Add(time.Minute))
+	if !before.Paused || before.Elapsed != after.Elapsed {
+		t.Errorf("The timer of a paused contribution advanced from %+v to %+v", before, after)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	doRequest(router, http.MethodPost, listPath+"/resume_contribution", "", token)
+	if response := doRequest(router, http.MethodPost, listPath+"/resume_contribution", "", token); response.Code != http.StatusConflict {
+		t.Errorf("Resuming twice returned status %d, expected %d", response.Code, http.StatusConflict)
+	}
+	doRequest(router, http.MethodPost, listPath+"/stop_contribution", "", token)
+
+	past := lists[listUuid].PastContributions
+	if len(past) != 1 || len(past[0].Pauses) != 1 || past[0].Paused {
+		t.Fatalf("The pause was not recorded: %+v", past)
+	}
+	contribution := past[0]
+	pause := contribution.Pauses[0].EndTime.Sub(contribution.Pauses[0].StartTime)
+	if pause < 20*time.Millisecond || contribution.Duration != contribution.EndTime.Sub(contribution.StartTime)-pause {
+		t.Errorf("The duration %v does not exclude the pause of %v", contribution.Duration, pause)
+	}
+
+	response := doRequest(router, http.MethodGet, listPath+"/mdreport", "", token)
+	if !strings.Contains(response.Body.String(), "| 1× (") {
+		t.Errorf("The report does not contain the pause:\n%s", response.Body.String())
+	}
+}
+
+func TestAutoStopSkipsPauses(t *testing.T) {
+	setupTestRouter(t)
+	listUuid, _, _ := addTestList(VisibilityPublic)
+
+	start := time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC)
+	listEntry := lists[listUuid]
+	listEntry.SpeakingTime = TalkingListSpeakingTime{Limit: time.Minute, AutoStop: true}
+	listEntry.CurrentContribution = TalkingListContribution{
+		InProgress: true,
+		StartTime:  start,
+		TimeLimit:  time.Minute,
+		Pauses:     []TalkingListPause{{StartTime: start.Add(10 * time.Second), EndTime: start.Add(40 * time.Second)}},
+	}
+	lists[listUuid] = listEntry
+
+	checkSpeakingTimes(start.Add(80 * time.Second))
+	if !lists[listUuid].CurrentContribution.InProgress {
+		t.Fatal("The contribution was stopped before its speaking time ran out")
+	}
+
+	checkSpeakingTimes(start.Add(95 * time.Second))
+	past := lists[listUuid].PastContributions
+	if len(past) != 1 || past[0].Duration != time.Minute || !past[0].EndTime.Equal(start.Add(90*time.Second)) {
+		t.Errorf("The contribution was stopped as %+v", past)
+	}
+}